@@ -0,0 +1,330 @@
+package bench
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"time"
+)
+
+// how often the heap is sampled while a codec runs, shorter intervals catch shorter peaks but slow the codec down
+const MEMORY_SAMPLE_INTERVAL = time.Millisecond
+
+// name of the rows summing up every file of a codec
+const TOTAL = "total"
+
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// a codec to compare, both funcs work on whole inputs in memory
+type Codec struct {
+	Name       string
+	Compress   func(input []byte) ([]byte, error)
+	Decompress func(compressed []byte) ([]byte, error)
+}
+
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// sizes are in bytes, PeakMemory is the highest sampled heap above what was in use before the codec started
+type Result struct {
+	File           string
+	Codec          string
+	OriginalSize   int64
+	CompressedSize int64
+	CompressTime   time.Duration
+	DecompressTime time.Duration
+	PeakMemory     uint64
+}
+
+func (r Result) Ratio() float64 {
+	if r.OriginalSize == 0 {
+		return 0
+	}
+
+	return float64(r.CompressedSize) / float64(r.OriginalSize)
+}
+
+func throughput(size int64, took time.Duration) float64 {
+	if took <= 0 {
+		return 0
+	}
+
+	return float64(size) / (1024 * 1024) / took.Seconds()
+}
+
+func (r Result) CompressThroughput() float64 {
+	return throughput(r.OriginalSize, r.CompressTime)
+}
+
+func (r Result) DecompressThroughput() float64 {
+	return throughput(r.OriginalSize, r.DecompressTime)
+}
+
+func StinkyCodec(level int) Codec {
+	return Codec{
+		Name: fmt.Sprintf("stinky-%d", level),
+		Compress: func(input []byte) ([]byte, error) {
+			return stinkycompressor.Compress(input, stinkycompressor.Options{Level: level})
+		},
+		Decompress: func(compressed []byte) ([]byte, error) {
+			return stinkycompressor.DecodeCompressedFile(compressed, false)
+		},
+	}
+}
+
+// the go standard library writers all take a level, readers decode every level
+func writerCodec(name string, newWriter func(io.Writer) (io.WriteCloser, error), newReader func(io.Reader) (io.ReadCloser, error)) Codec {
+	return Codec{
+		Name: name,
+		Compress: func(input []byte) ([]byte, error) {
+			out := &bytes.Buffer{}
+			writer, err := newWriter(out)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := writer.Write(input); err != nil {
+				return nil, err
+			}
+
+			if err := writer.Close(); err != nil {
+				return nil, err
+			}
+
+			return out.Bytes(), nil
+		},
+		Decompress: func(compressed []byte) ([]byte, error) {
+			reader, err := newReader(bytes.NewReader(compressed))
+			if err != nil {
+				return nil, err
+			}
+			defer reader.Close()
+
+			return io.ReadAll(reader)
+		},
+	}
+}
+
+func FlateCodec(level int) Codec {
+	return writerCodec("flate",
+		func(out io.Writer) (io.WriteCloser, error) { return flate.NewWriter(out, level) },
+		func(in io.Reader) (io.ReadCloser, error) { return flate.NewReader(in), nil })
+}
+
+func GzipCodec(level int) Codec {
+	return writerCodec("gzip",
+		func(out io.Writer) (io.WriteCloser, error) { return gzip.NewWriterLevel(out, level) },
+		func(in io.Reader) (io.ReadCloser, error) { return gzip.NewReader(in) })
+}
+
+func ZlibCodec(level int) Codec {
+	return writerCodec("zlib",
+		func(out io.Writer) (io.WriteCloser, error) { return zlib.NewWriterLevel(out, level) },
+		func(in io.Reader) (io.ReadCloser, error) { return zlib.NewReader(in) })
+}
+
+// Codecs are stinky at level against flate, gzip and zlib at their default level.
+// compress/bzip2 only decodes so bzip2 can not be compared the same way
+func Codecs(level int) []Codec {
+	return []Codec{
+		StinkyCodec(level),
+		FlateCodec(flate.DefaultCompression),
+		GzipCodec(gzip.DefaultCompression),
+		ZlibCodec(zlib.DefaultCompression),
+	}
+}
+
+// LoadCorpus reads every regular file under dir, named by their path relative to it
+func LoadCorpus(dir string) ([]File, error) {
+	files := []File{}
+	err := filepath.WalkDir(dir, func(walked string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		content, err := os.ReadFile(walked)
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, walked)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, File{Name: filepath.ToSlash(name), Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to read corpus %s", dir),
+			Err:      err,
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("corpus %s has no files", dir),
+		}
+	}
+
+	return files, nil
+}
+
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+
+	return sample[0].Value.Uint64()
+}
+
+// runs fn while sampling the heap, the heap is collected first so only what fn keeps alive is counted
+func peakMemory(fn func() error) (uint64, error) {
+	runtime.GC()
+	base := heapInUse()
+	peak := base
+
+	done, sampled := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(sampled)
+
+		ticker := time.NewTicker(MEMORY_SAMPLE_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				peak = max(peak, heapInUse())
+			}
+		}
+	}()
+
+	err := fn()
+	close(done)
+	<-sampled
+	peak = max(peak, heapInUse())
+
+	return peak - base, err
+}
+
+// the fastest of runs, timed without the memory sampling slowing it down
+func fastest(runs int, fn func() error) (time.Duration, error) {
+	best := time.Duration(0)
+	for run := range runs {
+		start := time.Now()
+		if err := fn(); err != nil {
+			return 0, err
+		}
+
+		if took := time.Since(start); run == 0 || took < best {
+			best = took
+		}
+	}
+
+	return best, nil
+}
+
+func runCodec(file File, codec Codec, runs int) (Result, error) {
+	result := Result{File: file.Name, Codec: codec.Name, OriginalSize: int64(len(file.Content))}
+
+	var compressed, decoded []byte
+	compressMemory, err := peakMemory(func() (err error) {
+		compressed, err = codec.Compress(file.Content)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	decompressMemory, err := peakMemory(func() (err error) {
+		decoded, err = codec.Decompress(compressed)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if !bytes.Equal(decoded, file.Content) {
+		return result, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("%s decoded %s to different content", codec.Name, file.Name),
+		}
+	}
+
+	result.CompressedSize = int64(len(compressed))
+	result.PeakMemory = max(compressMemory, decompressMemory)
+
+	result.CompressTime, err = fastest(runs, func() error {
+		_, err := codec.Compress(file.Content)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.DecompressTime, err = fastest(runs, func() error {
+		_, err := codec.Decompress(compressed)
+		return err
+	})
+
+	return result, err
+}
+
+// Run compresses and decompresses every file with every codec, timing the fastest of runs.
+// Results are grouped by file in the order of codecs and end with a TOTAL row per codec
+func Run(files []File, codecs []Codec, runs int) ([]Result, error) {
+	if runs < 1 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("runs must be at least 1, got %d", runs),
+		}
+	}
+
+	results := []Result{}
+	totals := make([]Result, len(codecs))
+	for idx, codec := range codecs {
+		totals[idx] = Result{File: TOTAL, Codec: codec.Name}
+	}
+
+	for _, file := range files {
+		for idx, codec := range codecs {
+			result, err := runCodec(file, codec, runs)
+			if err != nil {
+				return nil, &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.Code(err),
+					Message:  fmt.Sprintf("%s failed on %s", codec.Name, file.Name),
+					Err:      err,
+				}
+			}
+
+			results = append(results, result)
+			totals[idx].OriginalSize += result.OriginalSize
+			totals[idx].CompressedSize += result.CompressedSize
+			totals[idx].CompressTime += result.CompressTime
+			totals[idx].DecompressTime += result.DecompressTime
+			totals[idx].PeakMemory = max(totals[idx].PeakMemory, result.PeakMemory)
+		}
+	}
+
+	return append(results, totals...), nil
+}
@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func helperWriteCorpus(t *testing.T) string {
+	dir := t.TempDir()
+
+	text := []byte{}
+	for idx := range 2000 {
+		text = fmt.Appendf(text, "%d bobs burgers and fried ", idx)
+	}
+
+	files := map[string][]byte{
+		"text.txt":       text,
+		"nested/tiny.md": []byte("The ancient oak tree stood as a silent sentinel at the edge of the meadow."),
+		"empty":          {},
+	}
+
+	for name, content := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %+v", err)
+		}
+
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			t.Fatalf("WriteFile: %+v", err)
+		}
+	}
+
+	return dir
+}
+
+func TestRunComparesEveryCodecOnEveryFile(t *testing.T) {
+	files, err := LoadCorpus(helperWriteCorpus(t))
+	if err != nil {
+		t.Fatalf("LoadCorpus: %+v", err)
+	}
+
+	if len(files) != 3 || files[1].Name != "nested/tiny.md" {
+		t.Fatalf("expected the 3 corpus files in walk order, got %+v", files)
+	}
+
+	codecs := Codecs(4)
+	results, err := Run(files, codecs, 1)
+	if err != nil {
+		t.Fatalf("Run: %+v", err)
+	}
+
+	if len(results) != (len(files)+1)*len(codecs) {
+		t.Fatalf("expected a result per file and codec and the totals, got %d", len(results))
+	}
+
+	totals := results[len(files)*len(codecs):]
+	for idx, total := range totals {
+		if total.File != TOTAL || total.Codec != codecs[idx].Name {
+			t.Fatalf("expected totals in codec order, got %+v", total)
+		}
+
+		compressed := int64(0)
+		for _, result := range results[:len(files)*len(codecs)] {
+			if result.Codec == total.Codec {
+				compressed += result.CompressedSize
+			}
+		}
+
+		if total.CompressedSize != compressed || total.Ratio() <= 0 || total.Ratio() >= 1 {
+			t.Fatalf("total %+v does not sum up the files, expected %d compressed bytes", total, compressed)
+		}
+	}
+
+	markdown := &bytes.Buffer{}
+	if err := WriteMarkdown(markdown, results); err != nil {
+		t.Fatalf("WriteMarkdown: %+v", err)
+	}
+
+	if lines := strings.Count(markdown.String(), "\n"); lines != len(results)+2 {
+		t.Fatalf("expected a header, separator and %d rows, got %d lines", len(results), lines)
+	}
+
+	csvOut := &bytes.Buffer{}
+	if err := WriteCSV(csvOut, results); err != nil {
+		t.Fatalf("WriteCSV: %+v", err)
+	}
+
+	records, err := csv.NewReader(csvOut).ReadAll()
+	if err != nil || len(records) != len(results)+1 {
+		t.Fatalf("expected a header and %d records, got %d: %+v", len(results), len(records), err)
+	}
+}
+
+func TestRunRejectsBrokenCodec(t *testing.T) {
+	broken := Codec{
+		Name:       "broken",
+		Compress:   func(input []byte) ([]byte, error) { return input, nil },
+		Decompress: func(compressed []byte) ([]byte, error) { return compressed[1:], nil },
+	}
+
+	if _, err := Run([]File{{Name: "a", Content: []byte("bananas")}}, []Codec{broken}, 1); err == nil {
+		t.Fatalf("expected error for a codec not decoding back to its input")
+	}
+
+	if _, err := LoadCorpus(t.TempDir()); err == nil {
+		t.Fatalf("expected error for an empty corpus")
+	}
+}
@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var columns = []string{"file", "codec", "size", "compressed", "ratio", "compress MB/s", "decompress MB/s", "peak memory"}
+
+// WriteMarkdown writes results as a markdown table, ratios in percent and peak memory in KiB
+func WriteMarkdown(out io.Writer, results []Result) error {
+	fmt.Fprintf(out, "| %s |\n", strings.Join(columns, " | "))
+	fmt.Fprintf(out, "|%s\n", strings.Repeat("---|", len(columns)))
+
+	for _, result := range results {
+		file := result.File
+		if file == TOTAL {
+			file = "**" + TOTAL + "**"
+		}
+
+		_, err := fmt.Fprintf(out, "| %s | %s | %d | %d | %.2f%% | %.2f | %.2f | %d KiB |\n", file, result.Codec, result.OriginalSize, result.CompressedSize,
+			result.Ratio()*100, result.CompressThroughput(), result.DecompressThroughput(), result.PeakMemory/1024)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteCSV writes results with the exact numbers so runs of different versions can be diffed or plotted,
+// times are in nanoseconds and memory in bytes
+func WriteCSV(out io.Writer, results []Result) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"file", "codec", "size", "compressed", "ratio", "compress_ns", "decompress_ns", "compress_mbs", "decompress_mbs", "peak_memory"})
+
+	for _, result := range results {
+		writer.Write([]string{
+			result.File,
+			result.Codec,
+			strconv.FormatInt(result.OriginalSize, 10),
+			strconv.FormatInt(result.CompressedSize, 10),
+			strconv.FormatFloat(result.Ratio(), 'f', 6, 64),
+			strconv.FormatInt(result.CompressTime.Nanoseconds(), 10),
+			strconv.FormatInt(result.DecompressTime.Nanoseconds(), 10),
+			strconv.FormatFloat(result.CompressThroughput(), 'f', 3, 64),
+			strconv.FormatFloat(result.DecompressThroughput(), 'f', 3, 64),
+			strconv.FormatUint(result.PeakMemory, 10),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
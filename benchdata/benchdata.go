@@ -0,0 +1,156 @@
+// Package benchdata generates the inputs the transform benchmarks run on, the same seed always gives
+// the same bytes so runs of different versions can be compared with benchstat
+package benchdata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+const (
+	KB = 1024
+	MB = 1024 * KB
+)
+
+const (
+	PROFILE_TEXT       = "text"
+	PROFILE_BINARY     = "binary"
+	PROFILE_REPETITIVE = "repetitive"
+	PROFILE_RANDOM     = "random"
+)
+
+var PROFILES = []string{PROFILE_TEXT, PROFILE_BINARY, PROFILE_REPETITIVE, PROFILE_RANDOM}
+
+var SIZES = []int{KB, 64 * KB, MB, 16 * MB, 64 * MB}
+
+// the largest block a level codes at once is 900 KiB, block transforms never see more than this
+const MAX_BLOCK = MB
+
+// the largest block a container accepts
+const MAX_CONTAINER_BLOCK = 16 * MB
+
+var words = []string{
+	"the", "ancient", "oak", "tree", "stood", "as", "a", "silent", "sentinel", "at", "edge", "of", "meadow",
+	"its", "gnarled", "branches", "reaching", "skyward", "like", "fingers", "generation", "after", "had",
+	"sought", "shelter", "beneath", "broad", "canopy", "from", "summer", "picnics", "to", "winter", "storms",
+	"children", "climbed", "sturdy", "limbs", "lovers", "carved", "their", "initials", "into", "weathered",
+	"bark", "and", "birds", "built", "countless", "nests", "among", "leaves", "through", "drought", "flood",
+}
+
+// sentences of common words with zipf distributed frequencies, the way words show up in prose
+func text(rnd *rand.Rand, size int) []byte {
+	zipf := rand.NewZipf(rnd, 1.2, 1, uint64(len(words)-1))
+
+	out := make([]byte, 0, size+32)
+	for sentence := 0; len(out) < size; sentence++ {
+		length := 4 + rnd.IntN(12)
+		for idx := range length {
+			word := words[zipf.Uint64()]
+			if idx == 0 {
+				out = append(out, word[0]-'a'+'A')
+				word = word[1:]
+			}
+
+			out = append(out, word...)
+			if idx < length-1 {
+				out = append(out, ' ')
+			}
+		}
+
+		out = append(out, '.')
+		if sentence%5 == 4 {
+			out = append(out, '\n')
+		} else {
+			out = append(out, ' ')
+		}
+	}
+
+	return out[:size]
+}
+
+// fixed size records of an id, a small count, a float and a few flag bytes, like a table dumped to disk
+func binaryRecords(rnd *rand.Rand, size int) []byte {
+	out := make([]byte, 0, size+24)
+	for id := uint32(0); len(out) < size; id++ {
+		out = binary.LittleEndian.AppendUint32(out, id)
+		out = binary.LittleEndian.AppendUint16(out, uint16(rnd.IntN(100)))
+		out = binary.LittleEndian.AppendUint64(out, math.Float64bits(rnd.NormFloat64()*10))
+		out = append(out, byte(rnd.IntN(4)), 0, 0, 0)
+	}
+
+	return out[:size]
+}
+
+// log lines from the same template, only the counters and a few fields change
+func repetitive(rnd *rand.Rand, size int) []byte {
+	statuses := []int{200, 200, 200, 200, 304, 404, 500}
+
+	out := make([]byte, 0, size+128)
+	for line := 0; len(out) < size; line++ {
+		out = fmt.Appendf(out, "2024-05-01T12:%02d:%02d INFO request id=%d path=/api/v1/items/%d status=%d\n",
+			line/60%60, line%60, line, rnd.IntN(64), statuses[rnd.IntN(len(statuses))])
+	}
+
+	return out[:size]
+}
+
+func random(rnd *rand.Rand, size int) []byte {
+	out := make([]byte, size)
+	for idx := range out {
+		out[idx] = byte(rnd.Uint32())
+	}
+
+	return out
+}
+
+// Generate returns size bytes of profile, panics on an unknown profile
+func Generate(profile string, size int) []byte {
+	rnd := rand.New(rand.NewPCG(uint64(size), 50))
+
+	switch profile {
+	case PROFILE_TEXT:
+		return text(rnd, size)
+	case PROFILE_BINARY:
+		return binaryRecords(rnd, size)
+	case PROFILE_REPETITIVE:
+		return repetitive(rnd, size)
+	case PROFILE_RANDOM:
+		return random(rnd, size)
+	}
+
+	panic(fmt.Sprintf("unknown benchmark profile %q", profile))
+}
+
+func SizeName(size int) string {
+	if size >= MB {
+		return fmt.Sprintf("%dMB", size/MB)
+	}
+
+	return fmt.Sprintf("%dKB", size/KB)
+}
+
+// Run runs fn as a sub benchmark for every profile and size up to maxSize, named profile/size.
+// Inputs are only generated for the sub benchmarks selected by -bench, allocations are reported
+// and throughput is counted in input bytes
+func Run(b *testing.B, maxSize int, fn func(b *testing.B, input []byte)) {
+	for _, profile := range PROFILES {
+		for _, size := range SIZES {
+			if size > maxSize {
+				break
+			}
+
+			b.Run(profile+"/"+SizeName(size), func(b *testing.B) {
+				input := Generate(profile, size)
+
+				b.ReportAllocs()
+				b.SetBytes(int64(len(input)))
+				b.ResetTimer()
+
+				fn(b, input)
+			})
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package benchdata
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateIsDeterministicAndSized(t *testing.T) {
+	for _, profile := range PROFILES {
+		for _, size := range []int{0, 1, 1000, 64 * KB} {
+			first := Generate(profile, size)
+			if len(first) != size {
+				t.Fatalf("expected %d bytes of %s, got %d", size, profile, len(first))
+			}
+
+			if !bytes.Equal(first, Generate(profile, size)) {
+				t.Fatalf("expected the same %d bytes of %s on every call", size, profile)
+			}
+		}
+	}
+
+	if SizeName(KB) != "1KB" || SizeName(64*MB) != "64MB" {
+		t.Fatalf("unexpected size names %s and %s", SizeName(KB), SizeName(64*MB))
+	}
+}
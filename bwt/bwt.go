@@ -1,6 +1,11 @@
 package bwt
 
-import "slices"
+import (
+	"fmt"
+	"slices"
+	sCError "stinky-compression/error"
+	"unsafe"
+)
 
 const PRIMARY_INDEX_MARKER = byte('%')
 
@@ -65,8 +70,38 @@ type bwtPair struct {
 	idx  int
 }
 
-func DecodeBwt(data []byte, primaryIdx int) []byte {
+// DecodeMemory estimates how many bytes inverting a bwt of size bytes needs, the sorted first column
+// takes a byte and an int per input byte on top of the input and the result
+func DecodeMemory(size int) int64 {
+	return int64(size) * int64(unsafe.Sizeof(bwtPair{})+2)
+}
+
+func DecodeBwt(data []byte, primaryIdx int) ([]byte, error) {
+	return DecodeBwtWithLimit(data, primaryIdx, 0)
+}
+
+// DecodeBwtWithLimit is DecodeBwt refusing to use more than maxMemory bytes (see DecodeMemory), 0 means no limit
+func DecodeBwtWithLimit(data []byte, primaryIdx int, maxMemory int64) ([]byte, error) {
 	size := len(data)
+	if maxMemory > 0 && DecodeMemory(size) > maxMemory {
+		return nil, &sCError.LimitError{
+			Limit:  sCError.LIMIT_MEMORY,
+			Needed: DecodeMemory(size),
+			Max:    maxMemory,
+		}
+	}
+
+	if size == 0 && primaryIdx == 0 {
+		return []byte{}, nil
+	}
+
+	if primaryIdx < 0 || primaryIdx >= size {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("bwt primary index %d out of range for %d bytes", primaryIdx, size),
+		}
+	}
 
 	firstCol := make([]bwtPair, size)
 	for idx := 0; idx < size; idx++ {
@@ -101,5 +136,13 @@ func DecodeBwt(data []byte, primaryIdx int) []byte {
 		row = firstCol[row].idx
 	}
 
-	return result[1:]
+	if result[0] != PRIMARY_INDEX_MARKER {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("bwt primary index %d does not point at the index marker", primaryIdx),
+		}
+	}
+
+	return result[1:], nil
 }
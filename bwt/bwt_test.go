@@ -1,6 +1,12 @@
 package bwt
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"stinky-compression/benchdata"
+	sCError "stinky-compression/error"
+	"testing"
+)
 
 func TestCanEncodeAndDecodeBWT(t *testing.T) {
 	input := "my favourite food is bananas"
@@ -8,7 +14,10 @@ func TestCanEncodeAndDecodeBWT(t *testing.T) {
 	encoded, pIndex := Bwt(asBts)
 	t.Log(string(encoded))
 
-	decoded := DecodeBwt(encoded, pIndex)
+	decoded, err := DecodeBwt(encoded, pIndex)
+	if err != nil {
+		t.Fatalf("DecodeBwt: %+v", err)
+	}
 
 	if string(decoded) != input {
 		t.Fatalf("decoded did not match input, got\n%s\nwanted\n%s\n", string(decoded), string(input))
@@ -22,10 +31,94 @@ func TestCanEncodeAndDecodeBWTWithLongInput(t *testing.T) {
 	encoded, pIndex := Bwt(asBts)
 	t.Log(string(encoded))
 
-	decoded := DecodeBwt(encoded, pIndex)
+	decoded, err := DecodeBwt(encoded, pIndex)
+	if err != nil {
+		t.Fatalf("DecodeBwt: %+v", err)
+	}
 
 	if string(decoded) != input {
 		t.Fatalf("decoded did not match input, got\n%s\nwanted\n%s\n", string(decoded), string(input))
 	}
 
 }
+
+func TestDecodeBwtRejectsInvalidPrimaryIndex(t *testing.T) {
+	encoded, pIndex := Bwt([]byte("my favourite food is bananas"))
+
+	for _, idx := range []int{-1, len(encoded), len(encoded) + 10} {
+		_, err := DecodeBwt(encoded, idx)
+		if err == nil {
+			t.Fatalf("expected error for primary index %d", idx)
+		}
+	}
+
+	wrongIdx := (pIndex + 1) % len(encoded)
+	_, err := DecodeBwt(encoded, wrongIdx)
+	if err == nil {
+		t.Fatalf("expected error for primary index %d not pointing at the marker", wrongIdx)
+	}
+}
+
+func FuzzBwt(f *testing.F) {
+	f.Add([]byte("my favourite food is bananas"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		// rotation sort gets slow on long repetitive input, the edge cases we care about are short
+		if len(input) > 4096 {
+			t.Skip()
+		}
+
+		encoded, pIndex := Bwt(input)
+		decoded, err := DecodeBwt(encoded, pIndex)
+		if err != nil {
+			t.Fatalf("DecodeBwt: %+v", err)
+		}
+
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("decoded did not match input, got\n%q\nwanted\n%q\n", decoded, input)
+		}
+	})
+}
+
+func FuzzDecodeBwt(f *testing.F) {
+	f.Add([]byte("sm%ebovtefd iuaaoaninrfo ss"), 3)
+
+	f.Fuzz(func(t *testing.T, data []byte, primaryIdx int) {
+		DecodeBwt(data, primaryIdx)
+	})
+}
+
+func TestDecodeBwtWithLimitRejectsLargeInput(t *testing.T) {
+	encoded, pIndex := Bwt([]byte("my favourite food is bananas"))
+
+	_, err := DecodeBwtWithLimit(encoded, pIndex, DecodeMemory(len(encoded))-1)
+	limitErr := &sCError.LimitError{}
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected LimitError, got %+v", err)
+	}
+
+	decoded, err := DecodeBwtWithLimit(encoded, pIndex, DecodeMemory(len(encoded)))
+	if err != nil || string(decoded) != "my favourite food is bananas" {
+		t.Fatalf("unexpected decoded %q within the limit: %+v", decoded, err)
+	}
+}
+
+// the rotation sort compares whole rotations, blocks stay at most benchdata.MAX_BLOCK
+func BenchmarkBwt(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_BLOCK, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			Bwt(input)
+		}
+	})
+}
+
+func BenchmarkDecodeBwt(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_BLOCK, func(b *testing.B, input []byte) {
+		encoded, pIndex := Bwt(input)
+		for b.Loop() {
+			if _, err := DecodeBwt(encoded, pIndex); err != nil {
+				b.Fatalf("DecodeBwt: %+v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+)
+
+const (
+	EXIT_OK = 0
+	// the command ran but failed, e.g. unreadable input or corrupt compressed file
+	EXIT_FAILURE = 1
+	// invalid flags or arguments
+	EXIT_USAGE = 2
+	// the compressed file is damaged, see -recovery and the repair command
+	EXIT_CORRUPT = 3
+	// content decoded but did not match its checksum
+	EXIT_CHECKSUM = 4
+	// the compressed file was written by a newer version
+	EXIT_UNSUPPORTED_VERSION = 5
+	// decoding would go over -max-output or -max-memory
+	EXIT_LIMIT          = 6
+	EXIT_WRONG_PASSWORD = 7
+	// stopped by Ctrl-C, 128 + SIGINT like a shell reports it
+	EXIT_INTERRUPTED = 130
+)
+
+var exitCodes = map[sCError.ErrorCode]int{
+	sCError.CODE_CORRUPT:             EXIT_CORRUPT,
+	sCError.CODE_CHECKSUM:            EXIT_CHECKSUM,
+	sCError.CODE_UNSUPPORTED_VERSION: EXIT_UNSUPPORTED_VERSION,
+	sCError.CODE_LIMIT_EXCEEDED:      EXIT_LIMIT,
+	sCError.CODE_INVALID_ARGUMENT:    EXIT_USAGE,
+	sCError.CODE_WRONG_PASSWORD:      EXIT_WRONG_PASSWORD,
+	sCError.CODE_CANCELED:            EXIT_INTERRUPTED,
+}
+
+// EXIT_FAILURE for anything without a more specific exit code
+func exitCode(err error) int {
+	if code, ok := exitCodes[sCError.Code(err)]; ok {
+		return code
+	}
+
+	return EXIT_FAILURE
+}
+
+// used in place of a file name to read from stdin or write to stdout
+const STD_STREAM = "-"
+
+func newFlagSet(name, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\n%s\n\nFlags:\n", PROGRAM_NAME, name, description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parses command flags, ok is false when the command should exit with the returned code
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if err == nil {
+		return EXIT_OK, true
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return EXIT_OK, false
+	}
+
+	return EXIT_USAGE, false
+}
+
+func printError(out io.Writer, err error) {
+	fmt.Fprintf(out, "%s\n", err.Error())
+}
+
+func usageError(out io.Writer, message string) int {
+	printError(out, &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_INVALID_ARGUMENT,
+		Message:  message,
+	})
+
+	return EXIT_USAGE
+}
+
+func failure(out io.Writer, err error) int {
+	printError(out, err)
+	return exitCode(err)
+}
+
+// our own messages go to stderr once output is written to stdout so they do not end up in the stream
+func messageWriter(toStdout bool) io.Writer {
+	if toStdout {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
+func readFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read file",
+			Err:      err,
+		}
+	}
+
+	return content, nil
+}
+
+func readSrc(src string) ([]byte, error) {
+	if src != STD_STREAM {
+		return readFile(src)
+	}
+
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "Failed to read stdin",
+			Err:      err,
+		}
+	}
+
+	return content, nil
+}
+
+func writeStdout(content []byte) error {
+	_, err := os.Stdout.Write(content)
+	if err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "Failed to write to stdout",
+			Err:      err,
+		}
+	}
+
+	return nil
+}
+
+func writeFile(filename string, content []byte, force bool) error {
+	return stinkycompressor.WriteFileAtomic(filename, content, force)
+}
+
+// an empty path means no dictionary
+func loadDictionary(path string) (*stinkycompressor.Dictionary, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return stinkycompressor.LoadDictionary(content)
+}
+
+// the password is the content of path without its trailing newline
+func readPassword(path string) ([]byte, error) {
+	content, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	password := bytes.TrimRight(content, "\r\n")
+	if len(password) == 0 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("password file %s is empty", path),
+		}
+	}
+
+	return password, nil
+}
+
+// damaged content is repaired from its recovery record first so its metadata can be read,
+// encrypted content is then decrypted with the password in passwordFile, anything else is returned as is
+// content without a recovery record, or too damaged to repair, is left for decoding to report
+func repairContent(content []byte) []byte {
+	if repaired, rebuilt, err := stinkycompressor.Repair(content); err == nil && rebuilt > 0 {
+		return repaired
+	}
+
+	return content
+}
+
+// repairs content and decrypts it when it is encrypted, the key is derived within opts.MaxMemory
+func openContent(content []byte, passwordFile string, opts stinkycompressor.DecodeOptions) ([]byte, error) {
+	content = repairContent(content)
+
+	metaR, err := stinkycompressor.ParseMetadata(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stinkycompressor.IsEncrypted(metaR) {
+		return content, nil
+	}
+
+	if passwordFile == "" {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file is encrypted, pass its password with -password-file",
+		}
+	}
+
+	password, err := readPassword(passwordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return stinkycompressor.DecryptWithOptions(content, password, opts)
+}
+
+// a password is only read when encrypt is set
+func encryptionPassword(encrypt bool, passwordFile string) ([]byte, error) {
+	if !encrypt {
+		return nil, nil
+	}
+
+	if passwordFile == "" {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "'encrypt' needs a 'password-file'",
+		}
+	}
+
+	return readPassword(passwordFile)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strings"
+	"testing"
+	"time"
+)
+
+func helperDamagedMetadata(t *testing.T, compressed []byte) string {
+	if _, err := stinkycompressor.ParseMetadata(compressed); err != nil {
+		t.Fatalf("ParseMetadata: %+v", err)
+	}
+
+	// right after the "<size>#" prefix, inside the metadata
+	copy(compressed[bytes.IndexByte(compressed, '#')+3:], "garbage")
+	if _, err := stinkycompressor.ParseMetadata(compressed); err == nil {
+		t.Fatalf("expected the metadata to be damaged")
+	}
+
+	src := filepath.Join(t.TempDir(), "damaged.stinkc")
+	if err := os.WriteFile(src, compressed, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	return src
+}
+
+func TestCommandsRepairDamagedMetadata(t *testing.T) {
+	input := []byte{}
+	for idx := range 3000 {
+		input = fmt.Appendf(input, "%d bobs burgers and fried ", idx)
+	}
+
+	compressed, err := stinkycompressor.Compress(input, stinkycompressor.Options{Level: 4, Recovery: 5})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+	src := helperDamagedMetadata(t, compressed)
+
+	if code := runInfo([]string{"-src", src}); code != EXIT_OK {
+		t.Fatalf("expected info to read a repairable file, got exit code %d", code)
+	}
+
+	if code := runTest([]string{"-src", src, "-no-progress"}); code != EXIT_OK {
+		t.Fatalf("expected test to pass on a repairable file, got exit code %d", code)
+	}
+
+	dest := filepath.Join(t.TempDir(), "decoded.txt")
+	if code := runDecompress([]string{"-src", src, "-dest", dest, "-no-progress"}); code != EXIT_OK {
+		t.Fatalf("expected decompress to repair the file, got exit code %d", code)
+	}
+
+	if decoded, err := os.ReadFile(dest); err != nil || !bytes.Equal(decoded, input) {
+		t.Fatalf("decompressed content does not match the input: %+v", err)
+	}
+
+	root := filepath.Join(t.TempDir(), "project")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %+v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "burgers.txt"), input, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	archived, err := stinkycompressor.CompressArchive([]string{root}, stinkycompressor.Options{Level: 4, Recovery: 5})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+	archiveSrc := helperDamagedMetadata(t, archived)
+
+	extractDest := t.TempDir()
+	if code := runExtract([]string{"-src", archiveSrc, "-dest", extractDest}); code != EXIT_OK {
+		t.Fatalf("expected extract to repair the archive, got exit code %d", code)
+	}
+
+	if extracted, err := os.ReadFile(filepath.Join(extractDest, "project/burgers.txt")); err != nil || !bytes.Equal(extracted, input) {
+		t.Fatalf("extracted content does not match the input: %+v", err)
+	}
+}
+
+func TestCompressFileDoesNotCountExistingOutput(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "burgers.txt")
+	if err := os.WriteFile(src, []byte("bobs burgers and fried bananas"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	if err := os.WriteFile(stinkycompressor.CompressedFileName(src), make([]byte, 5000), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	result := compressFile(context.Background(), src, false, false, stinkycompressor.Options{})
+	if result.err == nil {
+		t.Fatalf("expected error compressing over an existing file without force")
+	}
+
+	if result.outSize != 0 {
+		t.Fatalf("expected no output size for a failed file, got %d", result.outSize)
+	}
+}
+
+func TestPrintSummaryOnlyCountsCompressedFiles(t *testing.T) {
+	results := []compressResult{
+		{src: "burgers.txt", inSize: 1000, outSize: 400},
+		{src: "fried.txt", inSize: 3000, err: fmt.Errorf("destination exists")},
+	}
+
+	out := &bytes.Buffer{}
+	printSummary(out, results, time.Second)
+
+	if !strings.Contains(out.String(), "2 files, 1000 -> 400 bytes (40.00%), 1 failed") {
+		t.Fatalf("expected only the compressed file in the totals, got %q", out.String())
+	}
+}
+
+func TestExtractRangeStaysWithinDecodeLimits(t *testing.T) {
+	// level 4 blocks are 32K so this is four blocks, the output limit below is bigger than one and smaller than all
+	rnd := rand.New(rand.NewPCG(3, 4))
+	input := make([]byte, 32*1024*3+1000)
+	for idx := range input {
+		input[idx] = byte('a' + rnd.IntN(8))
+	}
+
+	compressed, err := stinkycompressor.Compress(input, stinkycompressor.Options{Level: 4})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "burgers.stinkc")
+	if err := os.WriteFile(src, compressed, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "range.txt")
+	if code := runExtract([]string{"-src", src, "-offset", "0", "-dest", dest, "-max-output", "50000"}); code != EXIT_LIMIT {
+		t.Fatalf("expected the output limit to stop a range longer than it, got exit code %d", code)
+	}
+
+	if code := runExtract([]string{"-src", src, "-offset", "0", "-dest", dest, "-max-block-size", "1000"}); code != EXIT_LIMIT {
+		t.Fatalf("expected the block size limit to apply to ranges, got exit code %d", code)
+	}
+
+	if code := runExtract([]string{"-src", src, "-offset", "40000", "-length", "13", "-dest", dest, "-max-output", "50000"}); code != EXIT_OK {
+		t.Fatalf("expected a range within the limits to decode, got exit code %d", code)
+	}
+
+	if extracted, err := os.ReadFile(dest); err != nil || !bytes.Equal(extracted, input[40000:40013]) {
+		t.Fatalf("unexpected range %q: %+v", extracted, err)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"time"
+)
+
+func runArchive(args []string) int {
+	fs := newFlagSet("archive", "Bundle files and directories into a single .stinkc archive: archive -o out.stinkc [flags] paths...")
+	out := fs.String("o", "", "Archive file to create, '-' for stdout")
+	level := fs.Int("level", stinkycompressor.DEFAULT_LEVEL, "Compression level from 1 (fastest) to 9 (best ratio)")
+	force := fs.Bool("force", false, "Overwrite an existing archive")
+	recovery := fs.Int("recovery", 0, "Percent of parity to add so damaged blocks can be repaired, 0 for none")
+	encrypt := fs.Bool("encrypt", false, "Encrypt the archive with the password from -password-file")
+	passwordFile := fs.String("password-file", "", "File holding the password to encrypt with")
+	noProgress := fs.Bool("no-progress", false, "Do not draw a progress bar on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	msgOut := messageWriter(*out == STD_STREAM)
+
+	if *out == "" {
+		return usageError(msgOut, "Missing 'o' parameter")
+	}
+
+	if fs.NArg() == 0 {
+		return usageError(msgOut, "Missing paths to archive")
+	}
+
+	if *level < stinkycompressor.MIN_LEVEL || *level > stinkycompressor.MAX_LEVEL {
+		return usageError(msgOut, fmt.Sprintf("'level' must be between %d and %d", stinkycompressor.MIN_LEVEL, stinkycompressor.MAX_LEVEL))
+	}
+
+	if *recovery < 0 || *recovery > stinkycompressor.MAX_RECOVERY_PERCENT {
+		return usageError(msgOut, fmt.Sprintf("'recovery' must be between 0 and %d", stinkycompressor.MAX_RECOVERY_PERCENT))
+	}
+
+	password, err := encryptionPassword(*encrypt, *passwordFile)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var bar *progressBar
+	if progressEnabled(os.Stderr, *noProgress) {
+		bar = newProgressBar(os.Stderr, "archiving", 0, 1)
+	}
+
+	compTime := time.Now()
+	compressed, err := stinkycompressor.CompressArchiveContext(ctx, fs.Args(), stinkycompressor.Options{
+		Level:    *level,
+		Password: password,
+		Recovery: *recovery,
+		Progress: bar.update(0),
+	})
+	bar.clear()
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	if *out == STD_STREAM {
+		err = writeStdout(compressed)
+	} else {
+		err = writeFile(*out, compressed, *force)
+	}
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	fmt.Fprintf(msgOut, "(info) compression took: %s\n", time.Since(compTime))
+	if *out != STD_STREAM {
+		fmt.Fprintf(msgOut, "(info) Archive %s saved\n", *out)
+	}
+
+	return EXIT_OK
+}
+
+// decodes only the blocks covering the requested range of a compressed file within the limits of opts
+// encrypted files can not be read in place and are decrypted as a whole first
+func extractRange(src string, offset, length int64, dest string, force bool, opts stinkycompressor.DecodeOptions, passwordFile string) error {
+	var compressed io.ReaderAt
+	var size int64
+	if src == STD_STREAM || passwordFile != "" {
+		content, err := readSrc(src)
+		if err != nil {
+			return err
+		}
+
+		content, err = openContent(content, passwordFile, opts)
+		if err != nil {
+			return err
+		}
+
+		compressed, size = bytes.NewReader(content), int64(len(content))
+	} else {
+		srcFile, err := os.Open(src)
+		if err != nil {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  "failed to open file",
+				Err:      err,
+			}
+		}
+		defer srcFile.Close()
+
+		info, err := srcFile.Stat()
+		if err != nil {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  "failed to stat file",
+				Err:      err,
+			}
+		}
+
+		compressed, size = srcFile, info.Size()
+	}
+
+	reader, err := stinkycompressor.NewReaderWithOptions(compressed, size, opts)
+	if err != nil {
+		return err
+	}
+
+	if offset > reader.Size() {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("offset %d is past the end of the %d decoded bytes", offset, reader.Size()),
+		}
+	}
+
+	if length < 0 || length > reader.Size()-offset {
+		length = reader.Size() - offset
+	}
+
+	// the size comes from the metadata, nothing is allocated for it before it is checked
+	if opts.MaxOutputSize > 0 && length > opts.MaxOutputSize {
+		return &sCError.LimitError{Limit: sCError.LIMIT_OUTPUT_SIZE, Needed: length, Max: opts.MaxOutputSize}
+	}
+
+	content := make([]byte, length)
+	if _, err := reader.ReadAt(content, offset); err != nil {
+		return err
+	}
+
+	if dest == "" || dest == STD_STREAM {
+		return writeStdout(content)
+	}
+
+	return writeFile(dest, content, force)
+}
+
+func runExtract(args []string) int {
+	fs := newFlagSet("extract", "Extract an archive, or only the given members of it: extract -src x.stinkc [flags] [members...]\n"+
+		"With -offset and/or -length a byte range of any .stinkc file is decoded to -dest, or stdout by default")
+	src := fs.String("src", "", "Archive or compressed file to extract, '-' for stdin")
+	dest := fs.String("dest", "", "Directory to extract into (default the current directory), or the file to write a byte range to")
+	offset := fs.Int64("offset", 0, "Start of the byte range to decode")
+	length := fs.Int64("length", -1, "Length of the byte range to decode, until the end by default")
+	force := fs.Bool("force", false, "Overwrite existing files in the destination, or the destination file of a byte range")
+	dictPath := fs.String("dict", "", "Dictionary the file was compressed with")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file")
+	maxOutput := fs.Int64("max-output", stinkycompressor.DEFAULT_MAX_OUTPUT_SIZE, "Refuse to decode more than this many bytes, 0 for no limit")
+	maxBlockSize := fs.Int64("max-block-size", stinkycompressor.DEFAULT_MAX_BLOCK_SIZE, "Refuse blocks that decode to more than this many bytes, 0 for no limit")
+	maxMemory := fs.Int64("max-memory", stinkycompressor.DEFAULT_MAX_MEMORY, "Refuse to use more than this many bytes of memory while decoding, 0 for no limit")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	byteRange := false
+	fs.Visit(func(f *flag.Flag) {
+		byteRange = byteRange || f.Name == "offset" || f.Name == "length"
+	})
+
+	msgOut := messageWriter(byteRange && (*dest == "" || *dest == STD_STREAM))
+
+	if *src == "" {
+		return usageError(msgOut, "Missing 'src' parameter")
+	}
+
+	dict, err := loadDictionary(*dictPath)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	opts := stinkycompressor.DecodeOptions{
+		Dictionary:    dict,
+		MaxOutputSize: *maxOutput,
+		MaxBlockSize:  *maxBlockSize,
+		MaxMemory:     *maxMemory,
+	}
+
+	if byteRange {
+		if *offset < 0 {
+			return usageError(msgOut, "'offset' can not be negative")
+		}
+
+		if fs.NArg() > 0 {
+			return usageError(msgOut, "Members can not be combined with a byte range")
+		}
+
+		decTime := time.Now()
+		if err := extractRange(*src, *offset, *length, *dest, *force, opts, *passwordFile); err != nil {
+			return failure(msgOut, err)
+		}
+
+		fmt.Fprintf(msgOut, "(info) decode took: %s\n", time.Since(decTime))
+		return EXIT_OK
+	}
+
+	if *dest == "" {
+		*dest = "."
+	}
+
+	content, err := readSrc(*src)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	decTime := time.Now()
+	content, err = openContent(content, *passwordFile, opts)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	extracted, err := stinkycompressor.ExtractArchive(content, *dest, fs.Args(), *force, opts)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	fmt.Fprintf(msgOut, "(info) Extracted %d entries to %s, took %s\n", len(extracted), *dest, time.Since(decTime))
+	return EXIT_OK
+}
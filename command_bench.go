@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"stinky-compression/bench"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"text/tabwriter"
+	"time"
+)
+
+func throughput(size int, took time.Duration) float64 {
+	if took <= 0 {
+		return 0
+	}
+
+	return float64(size) / (1024 * 1024) / took.Seconds()
+}
+
+const (
+	BENCH_MARKDOWN = "markdown"
+	BENCH_CSV      = "csv"
+)
+
+// every file of a corpus against the go standard library codecs, level 0 means the default level
+func benchCorpus(dir string, level, runs int, format string) int {
+	if level == 0 {
+		level = stinkycompressor.DEFAULT_LEVEL
+	}
+
+	files, err := bench.LoadCorpus(dir)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	results, err := bench.Run(files, bench.Codecs(level), runs)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	if format == BENCH_CSV {
+		err = bench.WriteCSV(os.Stdout, results)
+	} else {
+		err = bench.WriteMarkdown(os.Stdout, results)
+	}
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	return EXIT_OK
+}
+
+func runBench(args []string) int {
+	fs := newFlagSet("bench", "Compress and decompress a file in memory and report ratio and throughput per level,\nor compare a directory of files against flate, gzip and zlib with -corpus")
+	src := fs.String("src", "", "File to benchmark with, '-' for stdin")
+	level := fs.Int("level", 0, "Only benchmark this level, 0 runs every level (the default level with -corpus)")
+	corpus := fs.String("corpus", "", "Directory of files to compare against flate, gzip and zlib")
+	format := fs.String("format", BENCH_MARKDOWN, "Corpus results as a 'markdown' or 'csv' table")
+	runs := fs.Int("runs", 3, "Corpus timings are the fastest of this many runs")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" && *corpus == "" {
+		return usageError(os.Stdout, "Missing 'src' or 'corpus' parameter")
+	}
+
+	if *src != "" && *corpus != "" {
+		return usageError(os.Stdout, "Only one of 'src' and 'corpus' can be given")
+	}
+
+	if *level != 0 && (*level < stinkycompressor.MIN_LEVEL || *level > stinkycompressor.MAX_LEVEL) {
+		return usageError(os.Stdout, fmt.Sprintf("'level' must be 0 or between %d and %d", stinkycompressor.MIN_LEVEL, stinkycompressor.MAX_LEVEL))
+	}
+
+	if *corpus != "" {
+		if *format != BENCH_MARKDOWN && *format != BENCH_CSV {
+			return usageError(os.Stdout, fmt.Sprintf("'format' must be '%s' or '%s'", BENCH_MARKDOWN, BENCH_CSV))
+		}
+
+		if *runs < 1 {
+			return usageError(os.Stdout, "'runs' must be at least 1")
+		}
+
+		return benchCorpus(*corpus, *level, *runs, *format)
+	}
+
+	input, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	fromLevel, toLevel := stinkycompressor.MIN_LEVEL, stinkycompressor.MAX_LEVEL
+	if *level != 0 {
+		fromLevel, toLevel = *level, *level
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "level\tsize\tratio\tcompress\tMB/s\tdecompress\tMB/s\t")
+	for lvl := fromLevel; lvl <= toLevel; lvl++ {
+		compTime := time.Now()
+		compressed, err := stinkycompressor.Compress(input, stinkycompressor.Options{Level: lvl})
+		if err != nil {
+			out.Flush()
+			return failure(os.Stdout, err)
+		}
+		compSince := time.Since(compTime)
+
+		decTime := time.Now()
+		decoded, err := stinkycompressor.DecodeCompressedFile(compressed, false)
+		if err != nil {
+			out.Flush()
+			return failure(os.Stdout, err)
+		}
+		decSince := time.Since(decTime)
+
+		if !bytes.Equal(decoded, input) {
+			out.Flush()
+			return failure(os.Stdout, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("level %d decoded content did not match input", lvl),
+			})
+		}
+
+		ratio := 0.0
+		if len(input) > 0 {
+			ratio = float64(len(compressed)) / float64(len(input)) * 100
+		}
+
+		fmt.Fprintf(out, "%d\t%d\t%.2f%%\t%s\t%.2f\t%s\t%.2f\t\n", lvl, len(compressed), ratio, compSince.Round(time.Microsecond), throughput(len(input), compSince), decSince.Round(time.Microsecond), throughput(len(input), decSince))
+	}
+	out.Flush()
+
+	return EXIT_OK
+}
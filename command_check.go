@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"time"
+)
+
+func runTest(args []string) int {
+	fs := newFlagSet("test", "Decode a .stinkc file and verify its checksums without writing anything")
+	src := fs.String("src", "", "Compressed file to test, '-' for stdin")
+	dictPath := fs.String("dict", "", "Dictionary the file was compressed with")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file")
+	maxOutput := fs.Int64("max-output", stinkycompressor.DEFAULT_MAX_OUTPUT_SIZE, "Refuse to decode more than this many bytes, 0 for no limit")
+	maxBlockSize := fs.Int64("max-block-size", stinkycompressor.DEFAULT_MAX_BLOCK_SIZE, "Refuse blocks that decode to more than this many bytes, 0 for no limit")
+	maxMemory := fs.Int64("max-memory", stinkycompressor.DEFAULT_MAX_MEMORY, "Refuse to use more than this many bytes of memory while decoding, 0 for no limit")
+	noProgress := fs.Bool("no-progress", false, "Do not draw a progress bar on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	dict, err := loadDictionary(*dictPath)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	compressedContent, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	opts := stinkycompressor.DecodeOptions{
+		Dictionary:    dict,
+		MaxOutputSize: *maxOutput,
+		MaxBlockSize:  *maxBlockSize,
+		MaxMemory:     *maxMemory,
+	}
+
+	decTime := time.Now()
+	compressedContent, err = openContent(compressedContent, *passwordFile, opts)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var bar *progressBar
+	if progressEnabled(os.Stderr, *noProgress) {
+		bar = newProgressBar(os.Stderr, "decoding", 0, 1)
+	}
+
+	opts.Progress = bar.update(0)
+	decoded, err := stinkycompressor.DecodeContext(ctx, compressedContent, opts)
+	bar.clear()
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	fmt.Printf("(info) %s OK, %d bytes decoded in %s\n", *src, len(decoded), time.Since(decTime))
+	return EXIT_OK
+}
+
+func runCompare(args []string) int {
+	fs := newFlagSet("compare", "Check that two files have the same content, e.g. an input and its decompressed copy")
+	src := fs.String("src", "", "First file")
+	dest := fs.String("dest", "", "Second file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	if *dest == "" {
+		return usageError(os.Stdout, "Missing 'dest' parameter")
+	}
+
+	inputContent, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	compContent, err := readSrc(*dest)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	if string(inputContent) != string(compContent) {
+		fmt.Println("(error) decoded did not match encoded")
+		fmt.Printf("(error) decoded len: %d, input len: %d\n", len(compContent), len(inputContent))
+		return EXIT_FAILURE
+	}
+
+	fmt.Println("(info) Files matched :)")
+	return EXIT_OK
+}
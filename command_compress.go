@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strings"
+	"sync"
+	"time"
+)
+
+type compressResult struct {
+	src     string
+	dest    string
+	inSize  int64
+	outSize int64
+	stats   *stinkycompressor.Stats
+	err     error
+}
+
+// expands directories (only with recursive) to the regular files in them, already compressed files are skipped
+func collectInputs(paths []string, recursive bool) ([]string, []compressResult) {
+	files := []string{}
+	failed := []compressResult{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			failed = append(failed, compressResult{src: path, err: err})
+			continue
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		if !recursive {
+			failed = append(failed, compressResult{src: path, err: &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("%s is a directory, use -r to compress the files in it", path),
+			}})
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(walked string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				failed = append(failed, compressResult{src: walked, err: err})
+				return nil
+			}
+
+			if entry.Type().IsRegular() && !strings.HasSuffix(walked, "."+stinkycompressor.COMPRESSED_FILE_EXTENSION) {
+				files = append(files, walked)
+			}
+
+			return nil
+		})
+		if err != nil {
+			failed = append(failed, compressResult{src: path, err: err})
+		}
+	}
+
+	return files, failed
+}
+
+func compressFile(ctx context.Context, src string, removeSrc, force bool, opts stinkycompressor.Options) compressResult {
+	result := compressResult{src: src}
+
+	fileContent, err := readSrc(src)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.inSize = int64(len(fileContent))
+
+	result.dest, result.err = stinkycompressor.WriteCompressionToFileContext(ctx, fileContent, src, removeSrc, force, opts)
+	result.stats = opts.Stats
+	if result.err != nil {
+		// dest can be a file that was already there and is not ours to count
+		return result
+	}
+
+	if info, err := os.Stat(result.dest); err == nil {
+		result.outSize = info.Size()
+	}
+
+	return result
+}
+
+func printFileError(out io.Writer, path string, err error) {
+	message := err.Error()
+	if compErr, ok := err.(*sCError.CompressorError); ok {
+		message = compErr.Message
+	}
+
+	fmt.Fprintf(out, "(error) %s: %s\n", path, message)
+}
+
+// files not started yet once ctx is canceled fail without a message of their own
+func compressFiles(ctx context.Context, files []string, removeSrc, force bool, opts stinkycompressor.Options, jobs int, msgOut io.Writer, bar *progressBar, statsFormat string) []compressResult {
+	results := make([]compressResult, len(files))
+	toCompress := make(chan int)
+	msgLock := sync.Mutex{}
+
+	// a file given twice (e.g. on its own and through its directory with -r) would be written by two workers at once
+	destinations := map[string]string{}
+	for idx, src := range files {
+		dest := stinkycompressor.CompressedFileName(src)
+		if first, ok := destinations[dest]; ok {
+			results[idx] = compressResult{src: src, err: &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("would be compressed to %s, same as %s", dest, first),
+			}}
+			bar.clear()
+			printFileError(msgOut, src, results[idx].err)
+			continue
+		}
+
+		destinations[dest] = src
+	}
+
+	wg := sync.WaitGroup{}
+	for range min(jobs, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := range toCompress {
+				compTime := time.Now()
+				fileOpts := opts
+				fileOpts.Progress = bar.update(idx)
+				if statsFormat != "" {
+					fileOpts.Stats = &stinkycompressor.Stats{}
+				}
+				results[idx] = compressFile(ctx, files[idx], removeSrc, force, fileOpts)
+
+				msgLock.Lock()
+				bar.clear()
+				if results[idx].err != nil {
+					printFileError(msgOut, files[idx], results[idx].err)
+				} else {
+					fmt.Fprintf(msgOut, "(info) Compressed file %s saved, took %s\n", results[idx].dest, time.Since(compTime))
+					if statsFormat != "" {
+						printStats(msgOut, files[idx], results[idx].stats, statsFormat)
+					}
+				}
+				msgLock.Unlock()
+			}
+		}()
+	}
+
+	for idx := range files {
+		if results[idx].err != nil {
+			continue
+		}
+
+		select {
+		case toCompress <- idx:
+		case <-ctx.Done():
+			results[idx] = compressResult{src: files[idx], err: canceledError(ctx)}
+		}
+	}
+	close(toCompress)
+	wg.Wait()
+
+	return results
+}
+
+func printSummary(msgOut io.Writer, results []compressResult, took time.Duration) {
+	inTotal, outTotal, failed := int64(0), int64(0), 0
+	for _, result := range results {
+		// the ratio is of the files that were compressed
+		if result.err != nil {
+			failed++
+			continue
+		}
+
+		inTotal += result.inSize
+		outTotal += result.outSize
+	}
+
+	ratio := 0.0
+	if inTotal > 0 {
+		ratio = float64(outTotal) / float64(inTotal) * 100
+	}
+
+	fmt.Fprintf(msgOut, "(info) %d files, %d -> %d bytes (%.2f%%), %d failed, took %s\n", len(results), inTotal, outTotal, ratio, failed, took)
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(msgOut, "(error) failed: %s\n", result.src)
+		}
+	}
+}
+
+func runCompress(args []string) int {
+	fs := newFlagSet("compress", "Compress files to .stinkc next to them, or a single file to stdout with -c.\nFiles can be given with -src and/or as arguments: compress [flags] [paths...]")
+	src := fs.String("src", "", "Source file to compress, '-' for stdin")
+	level := fs.Int("level", stinkycompressor.DEFAULT_LEVEL, "Compression level from 1 (fastest) to 9 (best ratio)")
+	removeSrc := fs.Bool("remove-src", false, "Remove source files once their compressed file is written and verified")
+	force := fs.Bool("force", false, "Overwrite existing compressed files")
+	recursive := fs.Bool("r", false, "Compress every file in the given directories and their subdirectories")
+	jobs := fs.Int("j", runtime.NumCPU(), "How many files to compress in parallel")
+	debug := fs.Bool("debug", false, "Print the huffman tree of every block, compresses one file at a time")
+	dictPath := fs.String("dict", "", "Dictionary from the train command to compress with")
+	recovery := fs.Int("recovery", 0, "Percent of parity to add so damaged blocks can be repaired, 0 for none")
+	encrypt := fs.Bool("encrypt", false, "Encrypt the compressed files with the password from -password-file")
+	passwordFile := fs.String("password-file", "", "File holding the password to encrypt with")
+	noProgress := fs.Bool("no-progress", false, "Do not draw a progress bar on stderr")
+	statsFormat := fs.String("stats", "", "Print sizes and timings of every stage as 'text' or 'json'")
+	stdout := false
+	fs.BoolVar(&stdout, "c", false, "Write output to stdout and keep the source file")
+	fs.BoolVar(&stdout, "stdout", false, "Write output to stdout and keep the source file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	paths := fs.Args()
+	if *src != "" {
+		paths = append([]string{*src}, paths...)
+	}
+
+	stdout = stdout || *src == STD_STREAM || (len(paths) == 1 && paths[0] == STD_STREAM)
+	msgOut := messageWriter(stdout)
+
+	if len(paths) == 0 {
+		return usageError(msgOut, "Missing 'src' parameter or paths to compress")
+	}
+
+	if *level < stinkycompressor.MIN_LEVEL || *level > stinkycompressor.MAX_LEVEL {
+		return usageError(msgOut, fmt.Sprintf("'level' must be between %d and %d", stinkycompressor.MIN_LEVEL, stinkycompressor.MAX_LEVEL))
+	}
+
+	if *recovery < 0 || *recovery > stinkycompressor.MAX_RECOVERY_PERCENT {
+		return usageError(msgOut, fmt.Sprintf("'recovery' must be between 0 and %d", stinkycompressor.MAX_RECOVERY_PERCENT))
+	}
+
+	if *jobs < 1 {
+		return usageError(msgOut, "'j' must be at least 1")
+	}
+
+	if !validStatsFormat(*statsFormat) {
+		return usageError(msgOut, fmt.Sprintf("'stats' must be '%s' or '%s'", STATS_TEXT, STATS_JSON))
+	}
+
+	if stdout && *debug {
+		return usageError(msgOut, "'debug' prints to stdout and can not be used while writing output to stdout")
+	}
+
+	// the trees go straight to stdout, files compressed in parallel would mix them up
+	if *debug {
+		*jobs = 1
+	}
+
+	if stdout && (len(paths) > 1 || *recursive) {
+		return usageError(msgOut, "Only a single file can be written to stdout")
+	}
+
+	dict, err := loadDictionary(*dictPath)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	password, err := encryptionPassword(*encrypt, *passwordFile)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	opts := stinkycompressor.Options{
+		Level:      *level,
+		Debug:      *debug,
+		Dictionary: dict,
+		Password:   password,
+		Recovery:   *recovery,
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	showProgress := progressEnabled(os.Stderr, *noProgress)
+
+	compTime := time.Now()
+	if stdout {
+		fileContent, err := readSrc(paths[0])
+		if err != nil {
+			return failure(msgOut, err)
+		}
+
+		var bar *progressBar
+		if showProgress {
+			bar = newProgressBar(os.Stderr, "compressing", 0, 1)
+			opts.Progress = bar.update(0)
+		}
+
+		if *statsFormat != "" {
+			opts.Stats = &stinkycompressor.Stats{}
+		}
+
+		var compressed []byte
+		if info, statErr := os.Stat(paths[0]); paths[0] != STD_STREAM && statErr == nil {
+			compressed, err = stinkycompressor.CompressFileContext(ctx, fileContent, info, opts)
+		} else {
+			compressed, err = stinkycompressor.CompressContext(ctx, fileContent, opts)
+		}
+		bar.clear()
+		if err != nil {
+			return failure(msgOut, err)
+		}
+
+		if err := writeStdout(compressed); err != nil {
+			return failure(msgOut, err)
+		}
+
+		fmt.Fprintf(msgOut, "(info) compression took: %s\n", time.Since(compTime))
+		if opts.Stats != nil {
+			printStats(msgOut, paths[0], opts.Stats, *statsFormat)
+		}
+
+		return EXIT_OK
+	}
+
+	files, results := collectInputs(paths, *recursive)
+	for _, result := range results {
+		printFileError(msgOut, result.src, result.err)
+	}
+
+	var bar *progressBar
+	if showProgress {
+		total := int64(0)
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				total += info.Size()
+			}
+		}
+
+		bar = newProgressBar(os.Stderr, "compressing", total, len(files))
+	}
+
+	results = append(results, compressFiles(ctx, files, *removeSrc, *force, opts, *jobs, msgOut, bar, *statsFormat)...)
+	bar.clear()
+
+	if len(results) > 1 {
+		printSummary(msgOut, results, time.Since(compTime))
+	}
+
+	if ctx.Err() != nil {
+		return exitCode(canceledError(ctx))
+	}
+
+	for _, result := range results {
+		if result.err != nil {
+			return exitCode(result.err)
+		}
+	}
+
+	return EXIT_OK
+}
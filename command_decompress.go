@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"time"
+)
+
+func runDecompress(args []string) int {
+	fs := newFlagSet("decompress", "Decompress a .stinkc file to -dest, its original name next to it by default, or to stdout with -c")
+	src := fs.String("src", "", "Compressed file to decompress, '-' for stdin")
+	dest := fs.String("dest", "", "Where to save decompressed content, '-' for stdout (default the original file name)")
+	force := fs.Bool("force", false, "Overwrite an existing destination file")
+	dictPath := fs.String("dict", "", "Dictionary the file was compressed with")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file")
+	maxOutput := fs.Int64("max-output", stinkycompressor.DEFAULT_MAX_OUTPUT_SIZE, "Refuse to decode more than this many bytes, 0 for no limit")
+	maxBlockSize := fs.Int64("max-block-size", stinkycompressor.DEFAULT_MAX_BLOCK_SIZE, "Refuse blocks that decode to more than this many bytes, 0 for no limit")
+	maxMemory := fs.Int64("max-memory", stinkycompressor.DEFAULT_MAX_MEMORY, "Refuse to use more than this many bytes of memory while decoding, 0 for no limit")
+	noProgress := fs.Bool("no-progress", false, "Do not draw a progress bar on stderr")
+	stdout := false
+	fs.BoolVar(&stdout, "c", false, "Write output to stdout")
+	fs.BoolVar(&stdout, "stdout", false, "Write output to stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	stdout = stdout || *dest == STD_STREAM
+	msgOut := messageWriter(stdout)
+
+	if *src == "" {
+		return usageError(msgOut, "Missing 'src' parameter")
+	}
+
+	dict, err := loadDictionary(*dictPath)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	compressedContent, err := readSrc(*src)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	opts := stinkycompressor.DecodeOptions{
+		Dictionary:    dict,
+		MaxOutputSize: *maxOutput,
+		MaxBlockSize:  *maxBlockSize,
+		MaxMemory:     *maxMemory,
+	}
+
+	decTime := time.Now()
+	compressedContent, err = openContent(compressedContent, *passwordFile, opts)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var bar *progressBar
+	if progressEnabled(os.Stderr, *noProgress) {
+		bar = newProgressBar(os.Stderr, "decoding", 0, 1)
+	}
+
+	opts.Progress = bar.update(0)
+	decoded, err := stinkycompressor.DecodeContext(ctx, compressedContent, opts)
+	bar.clear()
+	if err != nil {
+		return failure(msgOut, err)
+	}
+	fmt.Fprintf(msgOut, "(info) decode took: %s\n", time.Since(decTime))
+
+	if stdout {
+		if err := writeStdout(decoded); err != nil {
+			return failure(msgOut, err)
+		}
+
+		return EXIT_OK
+	}
+
+	metaR, err := stinkycompressor.ParseMetadata(compressedContent)
+	if err != nil {
+		return failure(msgOut, err)
+	}
+
+	if *dest == "" {
+		*dest, err = stinkycompressor.DecompressedFileName(*src, metaR)
+		if err != nil {
+			return failure(msgOut, err)
+		}
+	}
+
+	if err := writeFile(*dest, decoded, *force); err != nil {
+		return failure(msgOut, err)
+	}
+
+	if err := stinkycompressor.RestoreFileInfo(*dest, metaR); err != nil {
+		return failure(msgOut, err)
+	}
+
+	fmt.Fprintf(msgOut, "(info) Decoded content saved at %s\n", *dest)
+	return EXIT_OK
+}
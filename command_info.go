@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	proto_data "stinky-compression/proto/proto-data"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"text/tabwriter"
+	"time"
+)
+
+func runInfo(args []string) int {
+	fs := newFlagSet("info", "Print metadata of a .stinkc file")
+	src := fs.String("src", "", "Compressed file to inspect, '-' for stdin")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file, without it only the encryption is shown")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	content, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+	content = repairContent(content)
+
+	metaR, err := stinkycompressor.ParseMetadata(content)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(out, "file:\t%s\n", *src)
+
+	compressedSize := len(content)
+	if encryption := metaR.GetEncrypted(); encryption != nil {
+		fmt.Fprintf(out, "encrypted:\taes-256-gcm, argon2id t=%d m=%dKiB p=%d\n", encryption.GetTime(), encryption.GetMemory(), encryption.GetThreads())
+		if *passwordFile == "" {
+			fmt.Fprintf(out, "compressed size:\t%d bytes\n", compressedSize)
+			out.Flush()
+			return EXIT_OK
+		}
+
+		content, err = openContent(content, *passwordFile, stinkycompressor.DecodeOptions{}.WithDefaultLimits())
+		if err != nil {
+			return failure(os.Stdout, err)
+		}
+
+		metaR, err = stinkycompressor.ParseMetadata(content)
+		if err != nil {
+			return failure(os.Stdout, err)
+		}
+	}
+
+	recovery, recoverySize, _ := stinkycompressor.RecoveryInfo(content)
+
+	ratio := 0.0
+	if metaR.GetOriginalSize() > 0 {
+		ratio = float64(compressedSize) / float64(metaR.GetOriginalSize()) * 100
+	}
+
+	fmt.Fprintf(out, "version:\t%d\n", metaR.GetVersion())
+	fmt.Fprintf(out, "original size:\t%d bytes\n", metaR.GetOriginalSize())
+	fmt.Fprintf(out, "compressed size:\t%d bytes\n", compressedSize)
+	fmt.Fprintf(out, "header and index size:\t%d bytes\n", int64(len(content))-metaR.GetEncodedLen()-recoverySize)
+	fmt.Fprintf(out, "ratio:\t%.2f%%\n", ratio)
+	fmt.Fprintf(out, "blocks:\t%d\n", len(stinkycompressor.Blocks(metaR)))
+	fmt.Fprintf(out, "checksum:\t%08x\n", metaR.GetChecksum())
+	if name, ok := stinkycompressor.OriginalName(metaR); ok {
+		fmt.Fprintf(out, "original name:\t%s\n", name)
+		fmt.Fprintf(out, "original mode:\t%s\n", os.FileMode(metaR.GetMode()))
+		fmt.Fprintf(out, "modified:\t%s\n", time.Unix(0, metaR.GetModTime()).Format(time.DateTime))
+	}
+	if metaR.GetDictionaryId() != 0 {
+		fmt.Fprintf(out, "dictionary:\t%08x\n", metaR.GetDictionaryId())
+	}
+	if recovery != nil {
+		fmt.Fprintf(out, "recovery:\t%d%% parity, %d bytes in %d byte shards\n", recovery.GetPercent(), recoverySize, recovery.GetShardSize())
+	}
+	if stinkycompressor.IsArchive(metaR) {
+		fmt.Fprintf(out, "archive entries:\t%d\n", len(metaR.GetEntries()))
+	}
+	out.Flush()
+
+	return EXIT_OK
+}
+
+func listEntries(metaR *proto_data.CompressedFileMetaData) {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "mode\tsize\tmodified\tpath")
+	for _, entry := range metaR.GetEntries() {
+		name := entry.GetPath()
+		if entry.GetLinkTarget() != "" {
+			name = fmt.Sprintf("%s -> %s", name, entry.GetLinkTarget())
+		}
+
+		modTime := time.Unix(0, entry.GetModTime()).Format(time.DateTime)
+		fmt.Fprintf(out, "%s\t%d\t%s\t%s\n", fs.FileMode(entry.GetMode()), entry.GetSize(), modTime, name)
+	}
+	out.Flush()
+}
+
+func runList(args []string) int {
+	fs := newFlagSet("list", "List the entries of a .stinkc archive, or the blocks of any .stinkc file")
+	src := fs.String("src", "", "Compressed file to list, '-' for stdin")
+	blocks := fs.Bool("blocks", false, "List blocks even when the file is an archive")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	content, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	content, err = openContent(content, *passwordFile, stinkycompressor.DecodeOptions{}.WithDefaultLimits())
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	metaR, err := stinkycompressor.ParseMetadata(content)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	if stinkycompressor.IsArchive(metaR) && !*blocks {
+		listEntries(metaR)
+		return EXIT_OK
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "block\toriginal\tencoded\tpipeline\ttable\tchecksum\t")
+	for idx, block := range stinkycompressor.Blocks(metaR) {
+		fmt.Fprintf(out, "%d\t%d\t%d\t%s\t%s\t%08x\t\n", idx, block.GetOriginalSize(), block.GetEncodedLen(), blockPipeline(block), blockTableName(block), block.GetChecksum())
+	}
+	out.Flush()
+
+	return EXIT_OK
+}
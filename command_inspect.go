@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"stinky-compression/huffman"
+	proto_data "stinky-compression/proto/proto-data"
+	stinkycompressor "stinky-compression/stinky-compressor"
+)
+
+const (
+	INSPECT_TABLE = "table"
+	INSPECT_DOT   = "dot"
+	INSPECT_JSON  = "json"
+)
+
+func blockPipeline(block *proto_data.CompressedFileMetaData_Block) string {
+	if block.GetHuffmanOnly() {
+		return "huffman"
+	}
+
+	return "bwt+rle+mft+huffman"
+}
+
+func blockTableName(block *proto_data.CompressedFileMetaData_Block) string {
+	if block.GetDictionaryTable() {
+		return "dictionary"
+	}
+
+	if static, err := huffman.StaticTableByID(block.GetStaticTable()); err == nil {
+		return "static " + static.Name
+	}
+
+	if len(block.GetTables()) > 0 {
+		return fmt.Sprintf("%d own", len(block.GetTables())+1)
+	}
+
+	return "own"
+}
+
+// blocks coded with several tables are inspected once per table
+type inspectedBlock struct {
+	Block    int            `json:"block"`
+	Pipeline string         `json:"pipeline"`
+	Table    string         `json:"table"`
+	TableIdx int            `json:"table_idx"`
+	Tables   int            `json:"tables"`
+	Codes    []huffman.Code `json:"codes"`
+	Tree     *huffman.Node  `json:"tree"`
+}
+
+func writeInspected(out io.Writer, src string, blocks []inspectedBlock, format string) error {
+	switch format {
+	case INSPECT_JSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			File   string           `json:"file"`
+			Blocks []inspectedBlock `json:"blocks"`
+		}{File: src, Blocks: blocks})
+	case INSPECT_DOT:
+		for _, block := range blocks {
+			name := fmt.Sprintf("block %d", block.Block)
+			if block.TableIdx > 0 {
+				name = fmt.Sprintf("block %d table %d", block.Block, block.TableIdx+1)
+			}
+
+			if err := block.Tree.WriteDot(out, name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for idx, block := range blocks {
+		if idx > 0 {
+			fmt.Fprintln(out)
+		}
+
+		table := block.Table + " table"
+		if block.Tables > 1 {
+			table = fmt.Sprintf("own table %d of %d", block.TableIdx+1, block.Tables)
+		}
+
+		fmt.Fprintf(out, "block %d: %s, %s, %d symbols\n", block.Block, block.Pipeline, table, len(block.Codes))
+		if err := huffman.WriteCodeTable(out, block.Codes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runInspect(args []string) int {
+	fs := newFlagSet("inspect", "Export the huffman trees and code tables of a .stinkc file.\nSymbols of bwt blocks are mft ranks, not the bytes of the original content")
+	src := fs.String("src", "", "Compressed file to inspect, '-' for stdin")
+	block := fs.Int("block", -1, "Only inspect this block, -1 for all of them")
+	format := fs.String("format", INSPECT_TABLE, "Output as a code 'table', graphviz 'dot' or 'json' with the trees and code tables")
+	dictPath := fs.String("dict", "", "Dictionary the file was compressed with, needed for blocks coded with its table")
+	passwordFile := fs.String("password-file", "", "File holding the password of an encrypted file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	if *format != INSPECT_TABLE && *format != INSPECT_DOT && *format != INSPECT_JSON {
+		return usageError(os.Stdout, fmt.Sprintf("'format' must be '%s', '%s' or '%s'", INSPECT_TABLE, INSPECT_DOT, INSPECT_JSON))
+	}
+
+	dict, err := loadDictionary(*dictPath)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	content, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	content, err = openContent(content, *passwordFile, stinkycompressor.DecodeOptions{}.WithDefaultLimits())
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	metaR, err := stinkycompressor.ParseMetadata(content)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	blocks := stinkycompressor.Blocks(metaR)
+	if *block < -1 || *block >= len(blocks) {
+		return usageError(os.Stdout, fmt.Sprintf("'block' must be between 0 and %d", len(blocks)-1))
+	}
+
+	inspected := []inspectedBlock{}
+	for idx, metaBlock := range blocks {
+		if *block != -1 && idx != *block {
+			continue
+		}
+
+		// empty blocks have nothing coded
+		if metaBlock.GetOriginalSize() == 0 {
+			continue
+		}
+
+		tables, err := stinkycompressor.BlockTables(metaBlock, dict)
+		if err != nil {
+			return failure(os.Stdout, err)
+		}
+
+		for tableIdx, table := range tables {
+			if len(table) == 0 {
+				continue
+			}
+
+			inspected = append(inspected, inspectedBlock{
+				Block:    idx,
+				Pipeline: blockPipeline(metaBlock),
+				Table:    blockTableName(metaBlock),
+				TableIdx: tableIdx,
+				Tables:   len(tables),
+				Codes:    huffman.CodeTable(table),
+				Tree:     huffman.TreeFromFrequencies(table),
+			})
+		}
+	}
+
+	if err := writeInspected(os.Stdout, *src, inspected, *format); err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	return EXIT_OK
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	stinkycompressor "stinky-compression/stinky-compressor"
+)
+
+func runRepair(args []string) int {
+	fs := newFlagSet("repair", "Rebuild damaged blocks of a .stinkc file compressed with -recovery from its parity")
+	src := fs.String("src", "", "Damaged compressed file")
+	dest := fs.String("dest", "", "Where to save the repaired file (default replaces src)")
+	force := fs.Bool("force", false, "Overwrite an existing destination file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *src == "" {
+		return usageError(os.Stdout, "Missing 'src' parameter")
+	}
+
+	content, err := readSrc(*src)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	repaired, rebuilt, err := stinkycompressor.Repair(content)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	if rebuilt == 0 && *dest == "" {
+		fmt.Printf("(info) %s OK, nothing to repair\n", *src)
+		return EXIT_OK
+	}
+
+	// src is damaged anyway, replacing it needs no force
+	if *dest == "" {
+		*dest, *force = *src, true
+	}
+
+	if err := writeFile(*dest, repaired, *force); err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	fmt.Printf("(info) Rebuilt %d damaged shards, repaired file saved at %s\n", rebuilt, *dest)
+	return EXIT_OK
+}
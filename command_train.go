@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	stinkycompressor "stinky-compression/stinky-compressor"
+)
+
+func runTrain(args []string) int {
+	fs := newFlagSet("train", "Train a dictionary from sample files for compressing many small similar inputs: train -o x.dict [flags] samples...")
+	out := fs.String("o", "", "Dictionary file to create")
+	recursive := fs.Bool("r", false, "Use every file in the given directories and their subdirectories as a sample")
+	force := fs.Bool("force", false, "Overwrite an existing dictionary file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *out == "" {
+		return usageError(os.Stdout, "Missing 'o' parameter")
+	}
+
+	if fs.NArg() == 0 {
+		return usageError(os.Stdout, "Missing sample files to train from")
+	}
+
+	files, failed := collectInputs(fs.Args(), *recursive)
+	for _, result := range failed {
+		printFileError(os.Stdout, result.src, result.err)
+	}
+
+	if len(failed) > 0 {
+		return EXIT_FAILURE
+	}
+
+	samples := [][]byte{}
+	for _, sampleFile := range files {
+		content, err := readSrc(sampleFile)
+		if err != nil {
+			printFileError(os.Stdout, sampleFile, err)
+			return EXIT_FAILURE
+		}
+
+		samples = append(samples, content)
+	}
+
+	dict, err := stinkycompressor.TrainDictionary(samples)
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	content, err := dict.Marshal()
+	if err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	if err := writeFile(*out, content, *force); err != nil {
+		return failure(os.Stdout, err)
+	}
+
+	fmt.Printf("(info) Dictionary %08x trained from %d samples saved at %s\n", dict.ID, len(samples), *out)
+	return EXIT_OK
+}
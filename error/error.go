@@ -1,17 +1,193 @@
 package sCError
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
 	COMPRESSOR_ERROR_SEVERITY_ERROR = "error"
 	COMPRESSOR_ERROR_SEVERITY_INFO  = "info"
 )
 
+// block index used by ChecksumError when the whole file checksum did not match
+const CHECKSUM_WHOLE_FILE = -1
+
+// what kind of failure an error is, library users can branch on it and the cli maps it to an exit code
+type ErrorCode int
+
+const (
+	// anything not covered by the other codes
+	CODE_UNKNOWN ErrorCode = iota
+	// compressed data or metadata can not be decoded
+	CODE_CORRUPT
+	// content decoded but does not match its checksum
+	CODE_CHECKSUM
+	// written by a newer version of the format
+	CODE_UNSUPPORTED_VERSION
+	// decoding would go over a limit set by the caller
+	CODE_LIMIT_EXCEEDED
+	// reading or writing a file failed
+	CODE_IO
+	// invalid options or arguments from the caller
+	CODE_INVALID_ARGUMENT
+	// an encrypted file was opened with the wrong password
+	CODE_WRONG_PASSWORD
+	// the context passed in was canceled or ran out of time
+	CODE_CANCELED
+	// something no input should cause failed, e.g. marshaling metadata or setting up a cipher
+	CODE_INTERNAL
+)
+
+// sentinels to compare errors with errors.Is, every error of a code matches its sentinel
+var (
+	ErrCorrupt            = errors.New("corrupt compressed data")
+	ErrChecksum           = errors.New("checksum mismatch")
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	ErrLimitExceeded      = errors.New("limit exceeded")
+	ErrIO                 = errors.New("i/o failure")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrWrongPassword      = errors.New("wrong password")
+	ErrCanceled           = errors.New("canceled")
+	ErrInternal           = errors.New("internal error")
+)
+
+var codeSentinels = map[ErrorCode]error{
+	CODE_CORRUPT:             ErrCorrupt,
+	CODE_CHECKSUM:            ErrChecksum,
+	CODE_UNSUPPORTED_VERSION: ErrUnsupportedVersion,
+	CODE_LIMIT_EXCEEDED:      ErrLimitExceeded,
+	CODE_IO:                  ErrIO,
+	CODE_INVALID_ARGUMENT:    ErrInvalidArgument,
+	CODE_WRONG_PASSWORD:      ErrWrongPassword,
+	CODE_CANCELED:            ErrCanceled,
+	CODE_INTERNAL:            ErrInternal,
+}
+
+func (c ErrorCode) String() string {
+	if sentinel, ok := codeSentinels[c]; ok {
+		return sentinel.Error()
+	}
+
+	return "unknown"
+}
+
+// Code returns the code of the first error in err's tree that has one, CODE_UNKNOWN when none does.
+// The tree is walked depth first like errors.As does, so the errors joined by errors.Join are searched in order
+func Code(err error) ErrorCode {
+	if err == nil {
+		return CODE_UNKNOWN
+	}
+
+	switch typed := err.(type) {
+	case *CompressorError:
+		if typed.Code != CODE_UNKNOWN {
+			return typed.Code
+		}
+	case *ChecksumError:
+		return CODE_CHECKSUM
+	case *LimitError:
+		return CODE_LIMIT_EXCEEDED
+	case *WrongPasswordError:
+		return CODE_WRONG_PASSWORD
+	}
+
+	for code, sentinel := range codeSentinels {
+		if err == sentinel {
+			return code
+		}
+	}
+
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() error }:
+		return Code(wrapped.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, inner := range wrapped.Unwrap() {
+			if code := Code(inner); code != CODE_UNKNOWN {
+				return code
+			}
+		}
+	}
+
+	return CODE_UNKNOWN
+}
+
+// Err is the underlying error if there is one, it is printed after Message
 type CompressorError struct {
 	Severity string
+	Code     ErrorCode
 	Message  string
+	Err      error
 }
 
 func (ce *CompressorError) Error() string {
+	if ce.Err != nil {
+		return fmt.Sprintf("(%s) %s: %v", ce.Severity, ce.Message, ce.Err)
+	}
+
 	return fmt.Sprintf("(%s) %s", ce.Severity, ce.Message)
 }
+
+func (ce *CompressorError) Unwrap() error {
+	return ce.Err
+}
+
+func (ce *CompressorError) Is(target error) bool {
+	sentinel, ok := codeSentinels[ce.Code]
+	return ok && target == sentinel
+}
+
+// returned when decoded content does not match the checksum stored in the compressed file,
+// Block is the index of the failing block or CHECKSUM_WHOLE_FILE
+type ChecksumError struct {
+	Block    int
+	Expected uint32
+	Actual   uint32
+}
+
+func (ce *ChecksumError) Error() string {
+	if ce.Block == CHECKSUM_WHOLE_FILE {
+		return fmt.Sprintf("(%s) checksum mismatch for file, expected %08x got %08x", COMPRESSOR_ERROR_SEVERITY_ERROR, ce.Expected, ce.Actual)
+	}
+
+	return fmt.Sprintf("(%s) checksum mismatch for block %d, expected %08x got %08x", COMPRESSOR_ERROR_SEVERITY_ERROR, ce.Block, ce.Expected, ce.Actual)
+}
+
+func (ce *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum || target == ErrCorrupt
+}
+
+// returned when an encrypted file is opened with a password it was not encrypted with,
+// corrupt encrypted data is reported as a CompressorError instead
+type WrongPasswordError struct{}
+
+func (we *WrongPasswordError) Error() string {
+	return fmt.Sprintf("(%s) wrong password", COMPRESSOR_ERROR_SEVERITY_ERROR)
+}
+
+func (we *WrongPasswordError) Is(target error) bool {
+	return target == ErrWrongPassword
+}
+
+// limits a LimitError can be about
+const (
+	LIMIT_OUTPUT_SIZE = "output size"
+	LIMIT_BLOCK_SIZE  = "block size"
+	LIMIT_MEMORY      = "memory"
+)
+
+// returned when decoding would go over a limit set by the caller, e.g. for a decompression bomb.
+// Needed is how much the input asks for, Max the configured limit
+type LimitError struct {
+	Limit  string
+	Needed int64
+	Max    int64
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("(%s) %s limit exceeded, needs %d bytes with a limit of %d", COMPRESSOR_ERROR_SEVERITY_ERROR, le.Limit, le.Needed, le.Max)
+}
+
+func (le *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
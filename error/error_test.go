@@ -0,0 +1,134 @@
+package sCError
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestCompressorErrorMatchesSentinelOfItsCode(t *testing.T) {
+	err := &CompressorError{
+		Severity: COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     CODE_CORRUPT,
+		Message:  "failed to read block",
+	}
+
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected %+v to match ErrCorrupt", err)
+	}
+
+	if errors.Is(err, ErrChecksum) || errors.Is(err, ErrIO) {
+		t.Fatalf("expected %+v to only match ErrCorrupt", err)
+	}
+
+	uncoded := &CompressorError{Severity: COMPRESSOR_ERROR_SEVERITY_ERROR, Message: "whatever"}
+	for _, sentinel := range codeSentinels {
+		if errors.Is(uncoded, sentinel) {
+			t.Fatalf("expected an error without code to match no sentinel, matched %+v", sentinel)
+		}
+	}
+}
+
+func TestCompressorErrorUnwraps(t *testing.T) {
+	err := &CompressorError{
+		Severity: COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     CODE_IO,
+		Message:  "failed to read file",
+		Err:      &fs.PathError{Op: "open", Path: "missing.txt", Err: fs.ErrNotExist},
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) || !errors.Is(err, ErrIO) {
+		t.Fatalf("expected %+v to match both its cause and ErrIO", err)
+	}
+
+	pathErr := &fs.PathError{}
+	if !errors.As(err, &pathErr) || pathErr.Path != "missing.txt" {
+		t.Fatalf("expected to get the path error back, got %+v", pathErr)
+	}
+
+	want := "(error) failed to read file: open missing.txt: file does not exist"
+	if err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestCode(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want ErrorCode
+	}{
+		"nil":           {err: nil, want: CODE_UNKNOWN},
+		"plain":         {err: errors.New("plain"), want: CODE_UNKNOWN},
+		"sentinel":      {err: ErrUnsupportedVersion, want: CODE_UNSUPPORTED_VERSION},
+		"checksum":      {err: &ChecksumError{Block: 1}, want: CODE_CHECKSUM},
+		"limit":         {err: &LimitError{Limit: LIMIT_MEMORY}, want: CODE_LIMIT_EXCEEDED},
+		"password":      {err: &WrongPasswordError{}, want: CODE_WRONG_PASSWORD},
+		"fmt wrapped":   {err: fmt.Errorf("decoding: %w", &LimitError{}), want: CODE_LIMIT_EXCEEDED},
+		"compressor":    {err: &CompressorError{Code: CODE_INVALID_ARGUMENT}, want: CODE_INVALID_ARGUMENT},
+		"outer uncoded": {err: &CompressorError{Err: &ChecksumError{}}, want: CODE_CHECKSUM},
+		"outer wins": {
+			err:  &CompressorError{Code: CODE_IO, Err: &CompressorError{Code: CODE_CORRUPT}},
+			want: CODE_IO,
+		},
+		"joined": {
+			err:  errors.Join(errors.New("plain"), &ChecksumError{}, &CompressorError{Code: CODE_CORRUPT}),
+			want: CODE_CHECKSUM,
+		},
+		"joined inside": {
+			err:  &CompressorError{Err: fmt.Errorf("repairing: %w", errors.Join(errors.New("plain"), &LimitError{}))},
+			want: CODE_LIMIT_EXCEEDED,
+		},
+		"joined uncoded": {err: errors.Join(errors.New("plain"), &CompressorError{}), want: CODE_UNKNOWN},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Code(tc.err); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCodeFindsEveryCode(t *testing.T) {
+	for code, sentinel := range codeSentinels {
+		wrapped := &CompressorError{Severity: COMPRESSOR_ERROR_SEVERITY_ERROR, Code: code, Message: "failed"}
+
+		for _, err := range []error{
+			sentinel,
+			wrapped,
+			fmt.Errorf("outer: %w", sentinel),
+			&CompressorError{Severity: COMPRESSOR_ERROR_SEVERITY_ERROR, Message: "uncoded", Err: wrapped},
+			errors.Join(errors.New("plain"), wrapped),
+		} {
+			if got := Code(err); got != code {
+				t.Fatalf("expected %s for %+v, got %s", code, err, got)
+			}
+
+			if !errors.Is(err, sentinel) {
+				t.Fatalf("expected %+v to match %+v", err, sentinel)
+			}
+		}
+	}
+
+	// every code but CODE_UNKNOWN has a sentinel
+	if len(codeSentinels) != int(CODE_INTERNAL) {
+		t.Fatalf("expected a sentinel for each of the %d codes, got %d", CODE_INTERNAL, len(codeSentinels))
+	}
+}
+
+func TestTypedErrorsMatchSentinels(t *testing.T) {
+	checksumErr := error(&ChecksumError{Block: CHECKSUM_WHOLE_FILE})
+	if !errors.Is(checksumErr, ErrChecksum) || !errors.Is(checksumErr, ErrCorrupt) {
+		t.Fatalf("expected a checksum error to match ErrChecksum and ErrCorrupt")
+	}
+
+	if !errors.Is(&LimitError{}, ErrLimitExceeded) {
+		t.Fatalf("expected a limit error to match ErrLimitExceeded")
+	}
+
+	if !errors.Is(&WrongPasswordError{}, ErrWrongPassword) || errors.Is(&WrongPasswordError{}, ErrCorrupt) {
+		t.Fatalf("expected a wrong password error to only match ErrWrongPassword")
+	}
+}
@@ -2,4 +2,9 @@ module stinky-compression
 
 go 1.24.2
 
-require google.golang.org/protobuf v1.36.6
+require (
+	golang.org/x/crypto v0.48.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require golang.org/x/sys v0.41.0 // indirect
@@ -0,0 +1,152 @@
+package huffman
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// one symbol of a code table, Bits is its canonical code as it is written, most significant bit first
+type Code struct {
+	Symbol    byte   `json:"symbol"`
+	Frequency int    `json:"frequency"`
+	Length    int    `json:"length"`
+	Bits      string `json:"bits"`
+}
+
+// SymbolLabel shows ascii symbols as a quoted char and anything else as hex
+func SymbolLabel(symbol byte) string {
+	if symbol < utf8.RuneSelf {
+		return strconv.QuoteRuneToASCII(rune(symbol))
+	}
+
+	return fmt.Sprintf("0x%02x", symbol)
+}
+
+// CodeTable lists the canonical code of every symbol in table in the order codes are assigned,
+// shortest first and by symbol within a length. The single symbol of a run has no bits
+func CodeTable(table FrequencyTable) []Code {
+	if char, count, ok := table.Run(); ok {
+		return []Code{{Symbol: char, Frequency: count}}
+	}
+
+	codes := EncodingTable{}
+	treeToDict(TreeFromFrequencies(table), codes, &path{})
+
+	result := make([]Code, 0, len(codes))
+	for char, code := range codes {
+		result = append(result, Code{
+			Symbol:    char,
+			Frequency: table[char],
+			Length:    code.Size,
+			Bits:      fmt.Sprintf("%0*b", code.Size, code.Path),
+		})
+	}
+
+	slices.SortFunc(result, func(a, b Code) int {
+		if a.Length != b.Length {
+			return a.Length - b.Length
+		}
+
+		return int(a.Symbol) - int(b.Symbol)
+	})
+
+	return result
+}
+
+// WriteCodeTable writes codes as aligned columns
+func WriteCodeTable(out io.Writer, codes []Code) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "symbol\tchar\tfrequency\tlength\tbits")
+	for _, code := range codes {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\n", code.Symbol, SymbolLabel(code.Symbol), code.Frequency, code.Length, code.Bits)
+	}
+
+	return tw.Flush()
+}
+
+// frequencies of inner nodes are not kept up to date while a canonical tree is built, exports sum up the leaves instead
+func subtreeFrequency(node *Node) int {
+	if node == nil {
+		return 0
+	}
+
+	if node.IsLeaf() {
+		return node.Freq
+	}
+
+	return subtreeFrequency(node.Left) + subtreeFrequency(node.Right)
+}
+
+func dotEscape(label string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(label)
+}
+
+func writeDotNode(out io.Writer, node *Node, id *int) int {
+	nodeID := *id
+	*id++
+
+	if node.IsLeaf() {
+		fmt.Fprintf(out, "  n%d [shape=box, label=\"%d %s\\n%d\"];\n", nodeID, node.Char, dotEscape(SymbolLabel(node.Char)), node.Freq)
+		return nodeID
+	}
+
+	fmt.Fprintf(out, "  n%d [shape=circle, label=\"%d\"];\n", nodeID, subtreeFrequency(node))
+	for bit, child := range []*Node{node.Left, node.Right} {
+		if child == nil {
+			continue
+		}
+
+		childID := writeDotNode(out, child, id)
+		fmt.Fprintf(out, "  n%d -> n%d [label=\"%d\"];\n", nodeID, childID, bit)
+	}
+
+	return nodeID
+}
+
+// WriteDot writes the tree as a graphviz digraph, edges are labeled with the bit they code
+// and leaves with their symbol and frequency, e.g. render it with `dot -Tsvg`
+func (n *Node) WriteDot(out io.Writer, name string) error {
+	fmt.Fprintf(out, "digraph \"%s\" {\n", dotEscape(name))
+	fmt.Fprintln(out, "  node [fontname=\"monospace\"];")
+	id := 0
+	writeDotNode(out, n, &id)
+	_, err := fmt.Fprintln(out, "}")
+
+	return err
+}
+
+type jsonNode struct {
+	Symbol    *byte     `json:"symbol,omitempty"`
+	Frequency int       `json:"frequency"`
+	Bits      string    `json:"bits,omitempty"`
+	Zero      *jsonNode `json:"zero,omitempty"`
+	One       *jsonNode `json:"one,omitempty"`
+}
+
+func toJSONNode(node *Node, bits string) *jsonNode {
+	if node == nil {
+		return nil
+	}
+
+	if node.IsLeaf() {
+		symbol := node.Char
+		return &jsonNode{Symbol: &symbol, Frequency: node.Freq, Bits: bits}
+	}
+
+	return &jsonNode{
+		Frequency: subtreeFrequency(node),
+		Zero:      toJSONNode(node.Left, bits+"0"),
+		One:       toJSONNode(node.Right, bits+"1"),
+	}
+}
+
+// MarshalJSON nests the tree as {"frequency", "zero", "one"} objects, leaves carry their symbol and code bits
+func (n *Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toJSONNode(n, ""))
+}
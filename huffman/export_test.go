@@ -0,0 +1,130 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func helperExportTable() FrequencyTable {
+	table := FrequencyTable{}
+	for _, bt := range []byte(`The ancient "oak" tree stood as a silent sentinel at the edge of the meadow.\`) {
+		table[bt]++
+	}
+
+	return table
+}
+
+func TestCodeTableIsCanonicalAndMatchesEncoding(t *testing.T) {
+	table := helperExportTable()
+	codes := CodeTable(table)
+
+	if len(codes) != len(table) {
+		t.Fatalf("expected a code for each of the %d symbols, got %d", len(table), len(codes))
+	}
+
+	encoding := EncodingTable{}
+	treeToDict(TreeFromFrequencies(table), encoding, &path{})
+
+	for idx, code := range codes {
+		if code.Frequency != table[code.Symbol] || code.Length != encoding[code.Symbol].Size || len(code.Bits) != code.Length {
+			t.Fatalf("code %+v does not match its encoding %+v", code, encoding[code.Symbol])
+		}
+
+		if code.Bits != fmt.Sprintf("%0*b", encoding[code.Symbol].Size, encoding[code.Symbol].Path) {
+			t.Fatalf("code %+v bits do not match its encoding %+v", code, encoding[code.Symbol])
+		}
+
+		if idx == 0 {
+			continue
+		}
+
+		prev := codes[idx-1]
+		if prev.Length > code.Length || (prev.Length == code.Length && prev.Symbol >= code.Symbol) {
+			t.Fatalf("codes are not sorted: %+v before %+v", prev, code)
+		}
+
+		// canonical codes of the same length count up by one
+		if prev.Length == code.Length && prev.Bits >= code.Bits {
+			t.Fatalf("codes are not canonical: %+v before %+v", prev, code)
+		}
+	}
+
+	run := CodeTable(FrequencyTable{'a': 7})
+	if len(run) != 1 || run[0].Length != 0 || run[0].Frequency != 7 {
+		t.Fatalf("expected a single code without bits for a run, got %+v", run)
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteCodeTable(out, codes); err != nil {
+		t.Fatalf("WriteCodeTable: %+v", err)
+	}
+
+	if lines := strings.Count(out.String(), "\n"); lines != len(codes)+1 {
+		t.Fatalf("expected a header and %d rows, got %d lines", len(codes), lines)
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	table := helperExportTable()
+
+	out := &bytes.Buffer{}
+	if err := TreeFromFrequencies(table).WriteDot(out, "block 0"); err != nil {
+		t.Fatalf("WriteDot: %+v", err)
+	}
+
+	dot := out.String()
+	if !strings.HasPrefix(dot, "digraph \"block 0\" {\n") || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("expected a single digraph, got\n%s", dot)
+	}
+
+	// a full binary tree with n leaves has 2n-1 nodes connected by 2n-2 edges
+	if edges := strings.Count(dot, "->"); edges != 2*len(table)-2 {
+		t.Fatalf("expected %d edges, got %d", 2*len(table)-2, edges)
+	}
+
+	for _, label := range []string{`'\"'`, `'\\\\'`} {
+		if !strings.Contains(dot, label) {
+			t.Fatalf("expected escaped label %s in\n%s", label, dot)
+		}
+	}
+}
+
+func TestTreeMarshalsToJSON(t *testing.T) {
+	table := helperExportTable()
+
+	marshaled, err := json.Marshal(TreeFromFrequencies(table))
+	if err != nil {
+		t.Fatalf("json.Marshal: %+v", err)
+	}
+
+	var root jsonNode
+	if err := json.Unmarshal(marshaled, &root); err != nil {
+		t.Fatalf("json.Unmarshal: %+v", err)
+	}
+
+	leaves := map[byte]string{}
+	var walk func(node *jsonNode)
+	walk = func(node *jsonNode) {
+		if node.Symbol != nil {
+			leaves[*node.Symbol] = node.Bits
+			return
+		}
+
+		if node.Zero == nil || node.One == nil || node.Frequency != node.Zero.Frequency+node.One.Frequency {
+			t.Fatalf("inner node %+v does not sum up its children", node)
+		}
+
+		walk(node.Zero)
+		walk(node.One)
+	}
+	walk(&root)
+
+	for _, code := range CodeTable(table) {
+		if leaves[code.Symbol] != code.Bits {
+			t.Fatalf("symbol %d has bits %q in the tree, %q in the code table", code.Symbol, leaves[code.Symbol], code.Bits)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package huffman
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
+	"stinky-compression/benchdata"
 	"stinky-compression/file"
 	"testing"
 )
@@ -15,7 +17,10 @@ func TestCanEncodeAndDecodeStringCorrectly(t *testing.T) {
 			asBytes := []byte(input)
 
 			encoded, dict, bwtIdx, rleDict := HuffmanEncoding(asBytes, false)
-			decoded := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+			decoded, err := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+			if err != nil {
+				t.Fatalf("DecodeCompressionFromTable: %+v", err)
+			}
 
 			if len(decoded) != len(asBytes) {
 				t.Fatalf("decoded bytes len did not match input bytes len, got %d, wanted %d", len(decoded), len(asBytes))
@@ -35,7 +40,10 @@ func TestCanEncodeAndDecodeBinaryCorrectly(t *testing.T) {
 	}
 
 	encoded, dict, bwtIdx, rleDict := HuffmanEncoding(bts, false)
-	decoded := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+	decoded, err := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+	if err != nil {
+		t.Fatalf("DecodeCompressionFromTable: %+v", err)
+	}
 
 	if len(decoded) != len(bts) {
 		t.Fatalf("decoded bytes len did not match input bytes len, got %d, wanted %d\n", len(decoded), len(bts))
@@ -61,3 +69,241 @@ func TestCanEncodeAndDecodeBinaryCorrectly(t *testing.T) {
 	}
 
 }
+
+func FuzzHuffmanEncoding(f *testing.F) {
+	f.Add([]byte("The-ancient-oak tree stood as a silent sentinel at the edge of the meadow"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		// bwt rotation sort gets slow on long repetitive input, the edge cases we care about are short
+		if len(input) > 4096 {
+			t.Skip()
+		}
+
+		encoded, dict, bwtIdx, rleDict := HuffmanEncoding(input, false)
+		decoded, err := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+		if err != nil {
+			t.Fatalf("DecodeCompressionFromTable: %+v", err)
+		}
+
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("decoded did not match input got\n%q\nwanted\n%q", decoded, input)
+		}
+	})
+}
+
+func FuzzDecodeCompressionFromTable(f *testing.F) {
+	encoded, dict, bwtIdx, rleDict := HuffmanEncoding([]byte("bobs burgers"), false)
+
+	codes := []byte{}
+	for _, code := range encoded {
+		codes = append(codes, byte(code.Size), byte(code.Path))
+	}
+
+	freqs := []byte{}
+	for char, freq := range dict {
+		freqs = append(freqs, char, byte(freq))
+	}
+
+	counts := []byte{}
+	for _, count := range rleDict {
+		counts = append(counts, byte(count))
+	}
+
+	f.Add(codes, freqs, bwtIdx, counts)
+	f.Add([]byte{}, []byte{'a', 0xff}, 0, []byte{1})
+
+	f.Fuzz(func(t *testing.T, codes []byte, freqs []byte, bwtIdx int, counts []byte) {
+		// pairs of code size and path, sizes go past the depth of any tree these tables make
+		bits := []CharPathEncoding{}
+		for idx := 0; idx+1 < len(codes); idx += 2 {
+			bits = append(bits, CharPathEncoding{Size: int(codes[idx] % 32), Path: uint64(codes[idx+1])})
+		}
+
+		// pairs of symbol and signed frequency, rle counts are signed bytes as well
+		dict := FrequencyTable{}
+		for idx := 0; idx+1 < len(freqs); idx += 2 {
+			dict[freqs[idx]] = int(int8(freqs[idx+1]))
+		}
+
+		rleDict := make([]int32, len(counts))
+		for idx, count := range counts {
+			rleDict[idx] = int32(int8(count))
+		}
+
+		DecodeCompressionFromTable(bits, dict, bwtIdx, rleDict)
+	})
+}
+
+func TestCanEncodeAndDecodeSingleSymbol(t *testing.T) {
+	// the primary index marker is the only byte in the bwt output so every mft symbol is the same
+	input := []byte("%%%%%%%%")
+
+	encoded, dict, bwtIdx, rleDict := HuffmanEncoding(input, false)
+	if _, _, ok := dict.Run(); !ok {
+		t.Fatalf("expected a single symbol frequency table, got %+v", dict)
+	}
+
+	if len(encoded) != 0 {
+		t.Fatalf("expected no encoded codes for a single symbol, got %d", len(encoded))
+	}
+
+	decoded, err := DecodeCompressionFromTable(encoded, dict, bwtIdx, rleDict)
+	if err != nil {
+		t.Fatalf("DecodeCompressionFromTable: %+v", err)
+	}
+
+	if string(decoded) != string(input) {
+		t.Fatalf("decoded did not match input got\n%s\nwanted\n%s", decoded, input)
+	}
+}
+
+func TestCanEncodeAndDecodeHuffmanOnly(t *testing.T) {
+	input := []byte("The-ancient-oak tree stood as a silent sentinel at the edge of the meadow")
+
+	encoded, dict := HuffmanOnlyEncoding(input, false)
+	decoded, err := DecodeHuffmanOnly(encoded, dict)
+	if err != nil {
+		t.Fatalf("DecodeHuffmanOnly: %+v", err)
+	}
+
+	if string(decoded) != string(input) {
+		t.Fatalf("decoded did not match input got\n%s\nwanted\n%s", decoded, input)
+	}
+}
+
+func TestStaticTablesCoverEverySymbol(t *testing.T) {
+	seen := map[uint32]bool{}
+	for _, transformed := range []bool{false, true} {
+		for _, static := range StaticTables(transformed) {
+			if seen[static.ID] {
+				t.Fatalf("static table id %d is used twice", static.ID)
+			}
+			seen[static.ID] = true
+
+			if len(static.Table()) != 256 {
+				t.Fatalf("static table %s has %d symbols, expected 256", static.Name, len(static.Table()))
+			}
+
+			byID, err := StaticTableByID(static.ID)
+			if err != nil || byID.Name != static.Name {
+				t.Fatalf("StaticTableByID(%d) got %s: %+v", static.ID, byID.Name, err)
+			}
+		}
+	}
+
+	if _, err := StaticTableByID(0); err == nil {
+		t.Fatalf("expected error for static table 0")
+	}
+}
+
+func TestCanEncodeAndDecodeWithTable(t *testing.T) {
+	input := []byte("{\"name\": \"bob\", \"food\": [\"burger\", \"fries\"], \"id\": 12}\n\x00\xff")
+	static, err := StaticTableByID(STATIC_TABLE_JSON)
+	if err != nil {
+		t.Fatalf("StaticTableByID: %+v", err)
+	}
+
+	encoded, err := EncodeWithTable(input, static.Table())
+	if err != nil {
+		t.Fatalf("EncodeWithTable: %+v", err)
+	}
+
+	occurrences := FrequencyTable{}
+	for _, bt := range input {
+		occurrences[bt]++
+	}
+
+	bits := 0
+	for _, enc := range encoded {
+		bits += enc.Size
+	}
+
+	if size, ok := CodedSize(occurrences, static.Table()); !ok || size != bits {
+		t.Fatalf("CodedSize got %d, encoded %d bits", size, bits)
+	}
+
+	decoded, err := DecodeHuffmanOnly(encoded, static.Table())
+	if err != nil {
+		t.Fatalf("DecodeHuffmanOnly: %+v", err)
+	}
+
+	if string(decoded) != string(input) {
+		t.Fatalf("decoded did not match input got\n%s\nwanted\n%s", decoded, input)
+	}
+
+	if _, err := EncodeWithTable([]byte("abc"), FrequencyTable{'a': 1, 'b': 2}); err == nil {
+		t.Fatalf("expected error for a symbol the table has no code for")
+	}
+}
+
+func TestOptimizeTablesFitsGroupsThatDiffer(t *testing.T) {
+	// the first half only uses a few symbols and the second half others, one table has to code both
+	symbols := []byte{}
+	for idx := range 5000 {
+		symbols = append(symbols, byte(idx%3))
+	}
+	for idx := range 5000 {
+		symbols = append(symbols, byte(10+idx*7%16))
+	}
+
+	tables, selectors := OptimizeTables(symbols, 4, 3)
+	if len(tables) < 2 || len(selectors) != TableGroups(len(symbols)) {
+		t.Fatalf("expected several tables and a selector per group, got %d tables and %d selectors", len(tables), len(selectors))
+	}
+
+	if selectors[0] == selectors[len(selectors)-1] {
+		t.Fatalf("expected the two halves to pick different tables")
+	}
+
+	encoded, err := EncodeWithTables(symbols, tables, selectors)
+	if err != nil {
+		t.Fatalf("EncodeWithTables: %+v", err)
+	}
+
+	bits := 0
+	for _, enc := range encoded {
+		bits += enc.Size
+	}
+
+	single, _ := HuffmanOnlyEncoding(symbols, false)
+	singleBits := 0
+	for _, enc := range single {
+		singleBits += enc.Size
+	}
+
+	if len(encoded) != len(symbols) || bits >= singleBits {
+		t.Fatalf("several tables coded %d symbols in %d bits, one table %d bits", len(encoded), bits, singleBits)
+	}
+
+	if _, err := EncodeWithTables(symbols, tables, selectors[1:]); err == nil {
+		t.Fatalf("expected error for a missing selector")
+	}
+}
+
+// runs the whole bwt, rle, mft and huffman pipeline of a block
+func BenchmarkHuffmanEncoding(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_BLOCK, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			HuffmanEncoding(input, false)
+		}
+	})
+}
+
+func BenchmarkHuffmanOnlyEncoding(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_BLOCK, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			HuffmanOnlyEncoding(input, false)
+		}
+	})
+}
+
+func BenchmarkDecodeCompressionFromTable(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_BLOCK, func(b *testing.B, input []byte) {
+		encoded, table, pIndex, rleDict := HuffmanEncoding(input, false)
+		for b.Loop() {
+			if _, err := DecodeCompressionFromTable(encoded, table, pIndex, rleDict); err != nil {
+				b.Fatalf("DecodeCompressionFromTable: %+v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package huffman
+
+import (
+	"fmt"
+	sCError "stinky-compression/error"
+)
+
+// ids are stored in compressed files, a table must never change or be reused for another one once released
+const (
+	STATIC_TABLE_TEXT = 1
+	STATIC_TABLE_JSON = 2
+	STATIC_TABLE_GO   = 3
+	STATIC_TABLE_LOGS = 4
+	STATIC_TABLE_BWT  = 5
+)
+
+// StaticTable is a built in table for a common kind of content, blocks coded with one
+// only store its id instead of their own frequencies
+type StaticTable struct {
+	ID   uint32
+	Name string
+	// coded symbols are the bwt + rle + mft output instead of raw bytes
+	Transformed bool
+	// every symbol has a frequency so any input can be coded with the table
+	frequencies [256]uint16
+}
+
+func (t StaticTable) Table() FrequencyTable {
+	table := FrequencyTable{}
+	for char, freq := range t.frequencies {
+		table[byte(char)] = int(freq)
+	}
+
+	return table
+}
+
+// byte frequencies counted from a few MB of each kind of content, scaled so the most common symbol is 65535
+var staticTables = []StaticTable{
+	{
+		// English prose, licenses and READMEs
+		ID:          STATIC_TABLE_TEXT,
+		Name:        "text",
+		Transformed: false,
+		frequencies: [256]uint16{
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 201, 9428, 1, 5, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			65535, 15, 3795, 135, 204, 32, 28, 436, 1276, 1295, 1996, 34, 1843, 6247, 4505, 1402,
+			591, 768, 734, 465, 437, 417, 395, 394, 379, 338, 427, 150, 290, 1767, 297, 13,
+			108, 910, 276, 711, 508, 829, 382, 461, 297, 847, 41, 93, 612, 388, 550, 520,
+			536, 16, 677, 765, 1215, 388, 147, 273, 113, 188, 13, 145, 53, 145, 18, 607,
+			164, 20221, 4989, 10804, 10744, 34997, 6483, 6813, 11336, 20926, 540, 2228, 10158, 8005, 18640, 20815,
+			6754, 261, 17847, 16757, 27717, 7734, 2600, 4178, 1234, 3693, 230, 103, 8, 103, 18, 1,
+			77, 1, 12, 1, 1, 2, 1, 1, 2, 1, 1, 2, 1, 1, 1, 2,
+			2, 1, 1, 1, 67, 1, 1, 1, 13, 12, 1, 2, 3, 3, 1, 3,
+			1, 1, 1, 3, 1, 1, 1, 1, 1, 2, 1, 6, 2, 2, 2, 1,
+			1, 1, 1, 1, 1, 1, 2, 1, 4, 1, 1, 5, 2, 2, 2, 1,
+			1, 1, 10, 9, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			8, 2, 1, 1, 1, 1, 1, 3, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 92, 1, 2, 4, 1, 2, 1, 2, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		// package.json and other JSON config files
+		ID:          STATIC_TABLE_JSON,
+		Name:        "json",
+		Transformed: false,
+		frequencies: [256]uint16{
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 355, 9285, 1, 1, 2314, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			65535, 21, 21951, 153, 65, 108, 83, 37, 62, 61, 101, 256, 6373, 2542, 8198, 4057,
+			2963, 3228, 2166, 2546, 1701, 1329, 1527, 994, 1071, 828, 5861, 33, 35, 300, 65, 23,
+			188, 517, 399, 734, 493, 495, 293, 356, 311, 564, 247, 215, 391, 680, 553, 367,
+			497, 238, 482, 866, 597, 363, 412, 278, 212, 227, 231, 588, 98, 588, 330, 1955,
+			85, 9141, 2813, 6256, 4635, 14023, 2284, 2511, 3692, 9240, 691, 1177, 6590, 3849, 8465, 8087,
+			6018, 342, 7526, 9426, 9842, 3745, 1302, 758, 1338, 3021, 534, 1647, 38, 1642, 49, 1,
+			205, 219, 221, 207, 167, 191, 172, 167, 180, 178, 176, 178, 181, 178, 170, 182,
+			167, 167, 163, 158, 179, 189, 180, 178, 173, 176, 165, 171, 180, 175, 172, 167,
+			195, 195, 168, 182, 260, 204, 187, 188, 183, 162, 159, 147, 150, 161, 157, 156,
+			193, 167, 178, 169, 168, 169, 151, 167, 204, 189, 191, 187, 202, 200, 204, 181,
+			1, 1, 5, 45, 17, 11, 1, 3, 1, 2, 1, 3, 1, 1, 2, 1,
+			194, 85, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			74, 1, 67, 144, 173, 896, 914, 823, 801, 623, 67, 221, 245, 120, 18, 55,
+			213, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		// the Go standard library sources
+		ID:          STATIC_TABLE_GO,
+		Name:        "go",
+		Transformed: false,
+		frequencies: [256]uint16{
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 19319, 15544, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			65535, 723, 5432, 86, 36, 566, 595, 540, 5739, 5755, 1111, 460, 7955, 1025, 7065, 6049,
+			5442, 3101, 2670, 1833, 2137, 1168, 2091, 1009, 1387, 916, 3515, 425, 420, 4656, 330, 29,
+			15, 1957, 1037, 2045, 1490, 2514, 1470, 946, 794, 2011, 134, 315, 1353, 1235, 1463, 1743,
+			1921, 240, 2173, 3094, 2755, 750, 944, 528, 579, 400, 122, 1225, 1466, 1241, 33, 2863,
+			230, 16040, 4023, 9313, 8482, 29396, 8128, 5307, 5190, 16490, 432, 1931, 10187, 5680, 18429, 14148,
+			7001, 488, 19975, 14822, 23038, 7854, 2425, 2284, 5002, 3771, 528, 3427, 364, 3426, 41, 1,
+			18, 7, 9, 1, 1, 11, 1, 1, 13, 3, 1, 1, 2, 1, 1, 1,
+			1, 1, 1, 9, 2, 1, 1, 4, 1, 2, 2, 1, 7, 16, 2, 1,
+			1, 2, 1, 1, 9, 1, 1, 1, 1, 7, 1, 1, 1, 1, 1, 1,
+			1, 2, 2, 1, 1, 1, 1, 6, 1, 1, 1, 1, 1, 1, 1, 2,
+			1, 1, 14, 4, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 4, 2,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 42, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			9, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		// package manager and build logs
+		ID:          STATIC_TABLE_LOGS,
+		Name:        "logs",
+		Transformed: false,
+		frequencies: [256]uint16{
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 76, 7800, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			35904, 9, 81, 14, 11, 40, 28, 6685, 503, 505, 172, 134, 565, 14797, 50766, 65535,
+			2770, 10384, 7507, 12542, 4874, 3573, 8749, 7219, 8226, 2238, 1698, 254, 4, 528, 75, 2,
+			269, 338, 362, 4218, 1107, 991, 53, 370, 132, 2240, 3, 65, 2710, 756, 572, 849,
+			1624, 14, 393, 394, 911, 375, 72, 1580, 93, 81, 6, 58, 99, 58, 332, 8625,
+			8, 12770, 13830, 14099, 11172, 40159, 3955, 8840, 11034, 43205, 911, 2137, 27178, 12238, 40387, 42198,
+			28694, 282, 24691, 38316, 47118, 10962, 13097, 910, 2885, 21680, 561, 5, 461, 34, 1642, 1,
+			274, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 137, 137, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 274, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		// the bwt + rle + mft output of all of the above
+		ID:          STATIC_TABLE_BWT,
+		Name:        "bwt",
+		Transformed: true,
+		frequencies: [256]uint16{
+			1, 65535, 37625, 27497, 22038, 18415, 16092, 14112, 12627, 11217, 9995, 8941, 7971, 7069, 6223, 5601,
+			4920, 4442, 3941, 3541, 3150, 2753, 2455, 2209, 2018, 1782, 1584, 1433, 1280, 1161, 1042, 945,
+			830, 800, 681, 636, 614, 526, 531, 496, 459, 448, 427, 383, 397, 385, 536, 354,
+			306, 300, 302, 278, 240, 241, 235, 223, 224, 222, 222, 217, 194, 264, 209, 178,
+			159, 163, 151, 154, 139, 133, 134, 122, 116, 113, 115, 112, 101, 95, 99, 101,
+			104, 99, 97, 96, 90, 93, 78, 68, 61, 67, 61, 59, 61, 78, 69, 81,
+			76, 87, 93, 93, 109, 123, 143, 142, 165, 165, 161, 161, 167, 156, 183, 193,
+			189, 179, 214, 285, 338, 263, 218, 168, 209, 416, 164, 51, 45, 40, 18, 2,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1, 1,
+			1, 1, 1, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+}
+
+// StaticTables lists the tables for raw bytes, or for the bwt + rle + mft output when transformed
+func StaticTables(transformed bool) []StaticTable {
+	tables := []StaticTable{}
+	for _, table := range staticTables {
+		if table.Transformed == transformed {
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+func StaticTableByID(id uint32) (StaticTable, error) {
+	for _, table := range staticTables {
+		if table.ID == id {
+			return table, nil
+		}
+	}
+
+	return StaticTable{}, &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_CORRUPT,
+		Message:  fmt.Sprintf("unknown static table %d", id),
+	}
+}
@@ -0,0 +1,152 @@
+package huffman
+
+import (
+	"fmt"
+	sCError "stinky-compression/error"
+)
+
+// blocks coded with several tables switch tables every TABLE_GROUP_SIZE symbols
+const TABLE_GROUP_SIZE = 50
+
+// MAX_TABLES is the most tables a block is coded with, a selector fits in a byte either way
+const MAX_TABLES = 6
+
+// TableGroups is how many selectors a block of symbols coded with several tables has
+func TableGroups(symbols int) int {
+	return (symbols + TABLE_GROUP_SIZE - 1) / TABLE_GROUP_SIZE
+}
+
+func tableCodeLengths(table FrequencyTable) [256]int {
+	codes := EncodingTable{}
+	treeToDict(TreeFromFrequencies(table), codes, &path{})
+
+	lengths := [256]int{}
+	for char, code := range codes {
+		lengths[char] = code.Size
+	}
+
+	return lengths
+}
+
+// every table has a code for every symbol of the block so any group can pick any table
+func tablesFromSelectors(symbols []byte, count int, selectors []byte, alphabet FrequencyTable) []FrequencyTable {
+	tables := make([]FrequencyTable, count)
+	for idx := range tables {
+		tables[idx] = FrequencyTable{}
+		for char := range alphabet {
+			tables[idx][char] = 1
+		}
+	}
+
+	for group, selector := range selectors {
+		for _, char := range symbols[group*TABLE_GROUP_SIZE : min((group+1)*TABLE_GROUP_SIZE, len(symbols))] {
+			tables[selector][char]++
+		}
+	}
+
+	return tables
+}
+
+// OptimizeTables splits symbols into groups of TABLE_GROUP_SIZE and builds up to count tables for them the way bzip2 does:
+// the groups start split evenly between the tables in order, then every iteration builds each table from the groups
+// that picked it and lets every group pick the table it codes smallest with. Tables no group picked are dropped
+func OptimizeTables(symbols []byte, count int, iterations int) ([]FrequencyTable, []byte) {
+	groups := TableGroups(len(symbols))
+	count = max(min(count, groups, MAX_TABLES), 1)
+
+	alphabet := FrequencyTable{}
+	for _, char := range symbols {
+		alphabet[char] = 1
+	}
+
+	selectors := make([]byte, groups)
+	for group := range selectors {
+		selectors[group] = byte(group * count / groups)
+	}
+
+	tables := tablesFromSelectors(symbols, count, selectors, alphabet)
+	for range iterations {
+		lengths := make([][256]int, len(tables))
+		for idx, table := range tables {
+			lengths[idx] = tableCodeLengths(table)
+		}
+
+		for group := range selectors {
+			best, bestBits := 0, -1
+			for idx := range lengths {
+				bits := 0
+				for _, char := range symbols[group*TABLE_GROUP_SIZE : min((group+1)*TABLE_GROUP_SIZE, len(symbols))] {
+					bits += lengths[idx][char]
+				}
+
+				if bestBits == -1 || bits < bestBits {
+					best, bestBits = idx, bits
+				}
+			}
+
+			selectors[group] = byte(best)
+		}
+
+		tables = tablesFromSelectors(symbols, count, selectors, alphabet)
+	}
+
+	used := make([]int, count)
+	for idx := range used {
+		used[idx] = -1
+	}
+
+	kept := []FrequencyTable{}
+	for group, selector := range selectors {
+		if used[selector] == -1 {
+			used[selector] = len(kept)
+			kept = append(kept, tables[selector])
+		}
+
+		selectors[group] = byte(used[selector])
+	}
+
+	return kept, selectors
+}
+
+// EncodeWithTables codes every group of symbols with the table its selector picks
+func EncodeWithTables(symbols []byte, tables []FrequencyTable, selectors []byte) ([]CharPathEncoding, error) {
+	if len(selectors) != TableGroups(len(symbols)) {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("%d selectors for %d groups", len(selectors), TableGroups(len(symbols))),
+		}
+	}
+
+	codes := make([]EncodingTable, len(tables))
+	for idx, table := range tables {
+		codes[idx] = EncodingTable{}
+		treeToDict(TreeFromFrequencies(table), codes[idx], &path{})
+	}
+
+	encoded := make([]CharPathEncoding, 0, len(symbols))
+	for group, selector := range selectors {
+		if int(selector) >= len(codes) {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("selector %d picks table %d of %d", group, selector, len(codes)),
+			}
+		}
+
+		for _, char := range symbols[group*TABLE_GROUP_SIZE : min((group+1)*TABLE_GROUP_SIZE, len(symbols))] {
+			code, ok := codes[selector][char]
+			if !ok {
+				return nil, &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.CODE_INVALID_ARGUMENT,
+					Message:  fmt.Sprintf("table %d has no code for symbol %d", selector, char),
+				}
+			}
+
+			encoded = append(encoded, code)
+		}
+	}
+
+	return encoded, nil
+}
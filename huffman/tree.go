@@ -1,40 +1,49 @@
 package huffman
 
 import (
+	"bytes"
 	"container/heap"
 	"fmt"
+	"io"
+	"os"
 	"slices"
 	"stinky-compression/bwt"
+	sCError "stinky-compression/error"
 	"stinky-compression/mft"
 	proto_data "stinky-compression/proto/proto-data"
 	"stinky-compression/rle"
 )
 
-func printTree(node *Node, prefix string, isLeft bool, isFirst bool) {
+func printTree(out io.Writer, node *Node, prefix string, isLeft bool, isFirst bool) {
 	if node == nil {
 		return
 	}
 
 	if isLeft && !isFirst {
-		fmt.Print(prefix + "├─L-")
+		fmt.Fprint(out, prefix+"├─L-")
 		prefix += "│ "
 	} else if !isLeft && !isFirst {
-		fmt.Print(prefix + "└─R-")
+		fmt.Fprint(out, prefix+"└─R-")
 		prefix += " "
 	}
 
 	if node.IsAccNode {
-		fmt.Printf("(%d)\n", node.Freq)
+		fmt.Fprintf(out, "(%d)\n", node.Freq)
 	} else {
-		fmt.Printf("%d:%d\n", node.Char, node.Freq)
+		fmt.Fprintf(out, "%d:%d\n", node.Char, node.Freq)
 	}
 
-	printTree(node.Left, prefix, true, false)
-	printTree(node.Right, prefix, false, false)
+	printTree(out, node.Left, prefix, true, false)
+	printTree(out, node.Right, prefix, false, false)
 }
 
 func (n *Node) DebugTree() {
-	printTree(n, "", false, true)
+	n.WriteTree(os.Stdout)
+}
+
+// WriteTree writes the ascii tree DebugTree prints to out
+func (n *Node) WriteTree(out io.Writer) {
+	printTree(out, n, "", false, true)
 }
 
 type path struct {
@@ -118,14 +127,52 @@ func FrequencyTableToProto(table FrequencyTable) []*proto_data.CompressedFileMet
 	return protoTable
 }
 
-func ProtoFrequenciesToFrequencyTable(freqs []*proto_data.CompressedFileMetaData_Frequency) FrequencyTable {
+func ProtoFrequenciesToFrequencyTable(freqs []*proto_data.CompressedFileMetaData_Frequency) (FrequencyTable, error) {
 	table := FrequencyTable{}
 
-	for _, freq := range freqs {
+	for idx, freq := range freqs {
+		if len(freq.GetChar()) != 1 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("frequency %d has %d chars, expected 1", idx, len(freq.GetChar())),
+			}
+		}
+
+		if freq.GetFrequency() <= 0 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("frequency %d has invalid count %d", idx, freq.GetFrequency()),
+			}
+		}
+
+		if _, ok := table[freq.GetChar()[0]]; ok {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("frequency for char %d listed twice", freq.GetChar()[0]),
+			}
+		}
+
 		table[freq.GetChar()[0]] = int(freq.GetFrequency())
 	}
 
-	return table
+	return table, nil
+}
+
+// tables with a single distinct symbol have no huffman codes, the only frequency entry
+// works as a run header telling which symbol repeats and how many times
+func (table FrequencyTable) Run() (byte, int, bool) {
+	if len(table) != 1 {
+		return 0, 0, false
+	}
+
+	for char, freq := range table {
+		return char, freq, true
+	}
+
+	return 0, 0, false
 }
 
 type NodeHeap []*Node
@@ -348,16 +395,17 @@ func TreeFromFrequencies(input FrequencyTable) *Node {
 	return buildCanonicalTree(codes)
 }
 
-func HuffmanEncoding(input []byte, debugMode bool) ([]CharPathEncoding, FrequencyTable, int, []int32) {
-	bwtCoded, pIdx := bwt.Bwt(input)
-	rleCoded, rleDict := rle.Rle(bwtCoded)
-	mftCoded := mft.Mft(rleCoded)
-
+func huffmanCode(symbols []byte, debugMode bool) ([]CharPathEncoding, FrequencyTable) {
 	occurance := FrequencyTable{}
-	for _, bt := range mftCoded {
+	for _, bt := range symbols {
 		occurance[bt]++
 	}
 
+	// a single symbol has no code to write, its frequency entry alone describes the run
+	if _, _, ok := occurance.Run(); ok {
+		return []CharPathEncoding{}, occurance
+	}
+
 	asTree := TreeFromFrequencies(occurance)
 	if debugMode {
 		asTree.DebugTree()
@@ -367,35 +415,169 @@ func HuffmanEncoding(input []byte, debugMode bool) ([]CharPathEncoding, Frequenc
 	treeToDict(asTree, charDict, &path{})
 
 	encoded := []CharPathEncoding{}
-	for _, bt := range mftCoded {
+	for _, bt := range symbols {
 		encoded = append(encoded, charDict[bt])
 	}
 
+	return encoded, occurance
+}
+
+// runs the bwt, rle and mft steps that come before huffman coding, returns the symbols to code,
+// the bwt primary index and the rle dict
+func Transform(input []byte) ([]byte, int, []int32) {
+	bwtCoded, pIdx := bwt.Bwt(input)
+	rleCoded, rleDict := rle.Rle(bwtCoded)
+	mftCoded := mft.Mft(rleCoded)
+
+	return mftCoded, pIdx, rleDict
+}
+
+func HuffmanEncoding(input []byte, debugMode bool) ([]CharPathEncoding, FrequencyTable, int, []int32) {
+	mftCoded, pIdx, rleDict := Transform(input)
+
+	encoded, occurance := huffmanCode(mftCoded, debugMode)
+
 	return encoded, occurance, pIdx, rleDict
 }
 
-func DecodeCompressionFromTable(bits []CharPathEncoding, dict FrequencyTable, bwtIdx int, rleDict []int32) []byte {
+// codes the raw input bytes without bwt, rle or mft, much faster than HuffmanEncoding
+// but only as good as the plain byte frequencies allow
+func HuffmanOnlyEncoding(input []byte, debugMode bool) ([]CharPathEncoding, FrequencyTable) {
+	return huffmanCode(input, debugMode)
+}
+
+// CodedSize is how many bits symbols with the given occurrences take when coded with table,
+// ok is false when table has no code for one of them
+func CodedSize(occurrences FrequencyTable, table FrequencyTable) (int, bool) {
+	codes := EncodingTable{}
+	treeToDict(TreeFromFrequencies(table), codes, &path{})
+
+	bits := 0
+	for char, count := range occurrences {
+		code, ok := codes[char]
+		if !ok {
+			return 0, false
+		}
+
+		bits += count * code.Size
+	}
+
+	return bits, true
+}
+
+// EncodeWithTable codes symbols with a table that was not built from them, e.g. one trained from other inputs
+func EncodeWithTable(symbols []byte, table FrequencyTable) ([]CharPathEncoding, error) {
+	codes := EncodingTable{}
+	treeToDict(TreeFromFrequencies(table), codes, &path{})
+
+	encoded := make([]CharPathEncoding, 0, len(symbols))
+	for _, bt := range symbols {
+		code, ok := codes[bt]
+		if !ok {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("table has no code for symbol %d", bt),
+			}
+		}
+
+		encoded = append(encoded, code)
+	}
+
+	return encoded, nil
+}
+
+// returns the child of node for the given bit or an error when the tree has no such path
+func (n *Node) Walk(bit byte) (*Node, error) {
+	next := n.Left
+	if bit == 1 {
+		next = n.Right
+	}
+
+	if next == nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "encoded bits do not match any path in the huffman tree",
+		}
+	}
+
+	return next, nil
+}
+
+func (n *Node) IsLeaf() bool {
+	return n.Left == nil && n.Right == nil
+}
+
+func decodeSymbols(bits []CharPathEncoding, dict FrequencyTable) ([]byte, error) {
+	// tables from the metadata are checked when they are parsed, callers can pass anything
+	for char, freq := range dict {
+		if freq <= 0 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("char %d has invalid frequency %d", char, freq),
+			}
+		}
+	}
+
+	if len(dict) == 0 && len(bits) > 0 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("%d codes for an empty frequency table", len(bits)),
+		}
+	}
+
+	if char, count, ok := dict.Run(); ok {
+		return bytes.Repeat([]byte{char}, count), nil
+	}
+
 	tree := TreeFromFrequencies(dict)
 	decoded := []byte{}
 	for _, bit := range bits {
 		head := tree
 		for pos := bit.Size - 1; pos >= 0; pos-- {
 			b := (bit.Path >> uint(pos)) & 1
-			if b == 1 {
-				head = head.Right
-			} else {
-				head = head.Left
+			next, err := head.Walk(byte(b))
+			if err != nil {
+				return nil, err
+			}
+
+			head = next
+		}
+
+		if !head.IsLeaf() {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  "encoded bits end in the middle of the huffman tree",
 			}
 		}
 
 		decoded = append(decoded, head.Char)
 	}
 
+	return decoded, nil
+}
+
+func DecodeHuffmanOnly(bits []CharPathEncoding, dict FrequencyTable) ([]byte, error) {
+	return decodeSymbols(bits, dict)
+}
+
+func DecodeCompressionFromTable(bits []CharPathEncoding, dict FrequencyTable, bwtIdx int, rleDict []int32) ([]byte, error) {
+	decoded, err := decodeSymbols(bits, dict)
+	if err != nil {
+		return nil, err
+	}
+
 	mftDecodd := mft.DecodeMft(decoded)
-	rleDecoded := rle.DecodeRle(mftDecodd, rleDict)
-	bwtDecoded := bwt.DecodeBwt(rleDecoded, bwtIdx)
+	rleDecoded, err := rle.DecodeRle(mftDecodd, rleDict)
+	if err != nil {
+		return nil, err
+	}
 
-	return bwtDecoded
+	return bwt.DecodeBwt(rleDecoded, bwtIdx)
 }
 
 type EncodingTable map[byte]CharPathEncoding
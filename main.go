@@ -1,163 +1,61 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
-	sCError "stinky-compression/error"
-	"stinky-compression/file"
-	stinkycompressor "stinky-compression/stinky-compressor"
-	"time"
 )
 
-type config struct {
-	debug          bool
-	removeSrcFile  bool
-	decodeDestFile string
-	srcFile        string
-}
-
-func main() {
-	var cfg config
-	flag.BoolVar(&cfg.debug, "debug", false, "Run Stinky-Compressor in debug mode")
-	flag.BoolVar(&cfg.removeSrcFile, "remove-src", false, "Remove source file after compression")
-	flag.StringVar(&cfg.decodeDestFile, "decode-dest", "", "Where to save decoded content")
-	flag.StringVar(&cfg.srcFile, "src", "", "Source file to compress")
-	flag.Parse()
-
-	switch {
-	case flag.NArg() == 0:
-		if cfg.srcFile == "" {
-			err := &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  "Missing 'src' parameter",
-			}
-
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-
-		fileContent, err := file.ReadInputFile(cfg.srcFile)
-		if err != nil {
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-
-		compTime := time.Now()
-		compressedFileName, err := stinkycompressor.WriteCompressionToFile(fileContent, cfg.srcFile, cfg.removeSrcFile, cfg.debug)
-		if err != nil {
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-		compSince := time.Since(compTime)
-		fmt.Printf("(info) compression took: %s\n", compSince)
-		fmt.Printf("(info) Compressed file %s saved\n", compressedFileName)
-		os.Exit(0)
-	case flag.Arg(0) == "decode":
-		if cfg.decodeDestFile == "" {
-			err := &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  "Missing 'decode-dest' parameter",
-			}
-
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-
-		if cfg.srcFile == "" {
-			err := &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  "Missing 'src' parameter",
-			}
-
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-
-		compressedContent, err := file.ReadInputFile(cfg.srcFile)
-		if err != nil {
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-
-		decTime := time.Now()
-		decoded, err := stinkycompressor.DecodeCompressedFile(compressedContent, true)
-		if err != nil {
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-		decSince := time.Since(decTime)
-		fmt.Printf("(info) decode took: %s\n", decSince)
-
-		if !file.FileExists(cfg.decodeDestFile) {
-			err := file.CreateFile(cfg.decodeDestFile)
-			if err != nil {
-				fmt.Printf("%s\n", err.Error())
-				os.Exit(1)
-			}
-		}
-
-		fileToWrite, err := file.OpenFileWithWritePermissions(cfg.decodeDestFile)
-		if err != nil {
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
-		defer fileToWrite.Close()
-
-		_, err = fileToWrite.Write(decoded)
-		if err != nil {
-			err = &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  fmt.Sprintf("Failed to write decoded content to file: %+v", err),
-			}
-
-			fmt.Printf("%s\n", err)
-			os.Exit(1)
-		}
-
-		fmt.Printf("(info) Decoded content saved at %s\n", cfg.decodeDestFile)
-		os.Exit(0)
+const PROGRAM_NAME = "stinky-compressor"
 
-	case flag.Arg(0) == "compare":
-		if cfg.decodeDestFile == "" {
-			err := &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  "Missing 'decode-dest' parameter",
-			}
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
 
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
+var commands = []command{
+	{name: "compress", description: "Compress a file to .stinkc", run: runCompress},
+	{name: "decompress", description: "Decompress a .stinkc file", run: runDecompress},
+	{name: "test", description: "Decode a .stinkc file and verify its checksums without writing anything", run: runTest},
+	{name: "info", description: "Print metadata of a .stinkc file", run: runInfo},
+	{name: "list", description: "List the entries of a .stinkc archive or the blocks of a .stinkc file", run: runList},
+	{name: "train", description: "Train a dictionary from sample files for compressing small similar inputs", run: runTrain},
+	{name: "archive", description: "Bundle files and directories into a single .stinkc archive", run: runArchive},
+	{name: "extract", description: "Extract a .stinkc archive or single members of it", run: runExtract},
+	{name: "inspect", description: "Export the huffman trees and code tables of a .stinkc file as text, graphviz or json", run: runInspect},
+	{name: "repair", description: "Rebuild damaged blocks of a .stinkc file from its recovery record", run: runRepair},
+	{name: "bench", description: "Measure ratio and throughput of every compression level on a file", run: runBench},
+	{name: "compare", description: "Check that two files have the same content", run: runCompare},
+}
 
-		if cfg.srcFile == "" {
-			err := &sCError.CompressorError{
-				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  "Missing 'src' parameter",
-			}
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", PROGRAM_NAME)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.description)
+	}
 
-			fmt.Printf("%s\n", err.Error())
-			os.Exit(1)
-		}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command\n", PROGRAM_NAME)
+}
 
-		inputContent, err := file.ReadInputFile(cfg.srcFile)
-		if err != nil {
-			fmt.Printf("%s\n", err)
-			os.Exit(1)
-		}
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(EXIT_USAGE)
+	}
 
-		compContent, err := file.ReadInputFile(cfg.decodeDestFile)
-		if err != nil {
-			fmt.Printf("%s\n", err)
-			os.Exit(1)
-		}
+	name := os.Args[1]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		printUsage()
+		os.Exit(EXIT_OK)
+	}
 
-		if string(inputContent) != string(compContent) {
-			fmt.Println("(error) decoded did not match encoded")
-			fmt.Printf("(error) decoded len: %d, input len: %d\n", len(compContent), len(inputContent))
-			os.Exit(1)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(os.Args[2:]))
 		}
-
-		fmt.Println("(info) Files matched :)")
-		os.Exit(0)
 	}
+
+	fmt.Fprintf(os.Stderr, "(error) Unknown command '%s'\n\n", name)
+	printUsage()
+	os.Exit(EXIT_USAGE)
 }
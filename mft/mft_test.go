@@ -1,7 +1,9 @@
 package mft
 
 import (
+	"bytes"
 	"reflect"
+	"stinky-compression/benchdata"
 	"testing"
 )
 
@@ -31,3 +33,44 @@ func TestCanEncodeAndDecodeMftWithLongInput(t *testing.T) {
 		t.Fatal("decoded did not match encoded")
 	}
 }
+
+func FuzzMft(f *testing.F) {
+	f.Add([]byte("bananaaa"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		decoded := DecodeMft(Mft(input))
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("decoded did not match input. got\n%q\nwanted\n%q\n", decoded, input)
+		}
+	})
+}
+
+func FuzzDecodeMft(f *testing.F) {
+	f.Add([]byte{98, 98, 110, 1, 1, 1, 0, 0})
+	f.Add([]byte{255, 0, 255})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		// every input is a valid list of positions, decoding it is undone by encoding
+		encoded := Mft(DecodeMft(input))
+		if !bytes.Equal(encoded, input) {
+			t.Fatalf("encoded did not match input. got\n%v\nwanted\n%v\n", encoded, input)
+		}
+	})
+}
+
+func BenchmarkMft(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_CONTAINER_BLOCK, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			Mft(input)
+		}
+	})
+}
+
+func BenchmarkDecodeMft(b *testing.B) {
+	benchdata.Run(b, benchdata.MAX_CONTAINER_BLOCK, func(b *testing.B, input []byte) {
+		encoded := Mft(input)
+		for b.Loop() {
+			DecodeMft(encoded)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	PROGRESS_BAR_WIDTH = 30
+	// redrawing more often only costs time without anyone being able to read it
+	PROGRESS_REDRAW_INTERVAL = 100 * time.Millisecond
+)
+
+// canceled on the first Ctrl-C or SIGTERM, a second one kills the program right away
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
+func canceledError(ctx context.Context) error {
+	return &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_CANCELED,
+		Message:  "canceled",
+		Err:      ctx.Err(),
+	}
+}
+
+// only drawn when out is a terminal, progress lines would just clutter a log or pipe
+func progressEnabled(out *os.File, disabled bool) bool {
+	if disabled {
+		return false
+	}
+
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// a single line bar over several tasks (files) running at once, a nil bar draws nothing.
+// total is the input size of all tasks together, a bar with a single task takes it from the reports instead
+type progressBar struct {
+	out    io.Writer
+	label  string
+	total  int64
+	start  time.Time
+	lock   sync.Mutex
+	done   []int64
+	stage  string
+	drawn  time.Time
+	hidden bool
+}
+
+func newProgressBar(out io.Writer, label string, total int64, tasks int) *progressBar {
+	return &progressBar{out: out, label: label, total: total, start: time.Now(), done: make([]int64, tasks), hidden: true}
+}
+
+// the ProgressFunc reporting the progress of a single task
+func (b *progressBar) update(task int) stinkycompressor.ProgressFunc {
+	if b == nil {
+		return nil
+	}
+
+	return func(progress stinkycompressor.Progress) {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		b.done[task] = progress.BytesIn
+		if len(b.done) == 1 {
+			b.total = progress.Total
+		}
+		b.stage = progress.Stage
+		if time.Since(b.drawn) >= PROGRESS_REDRAW_INTERVAL || progress.Stage == stinkycompressor.STAGE_DONE {
+			b.draw()
+		}
+	}
+}
+
+func (b *progressBar) draw() {
+	done := int64(0)
+	for _, taskDone := range b.done {
+		done += taskDone
+	}
+
+	fraction := 1.0
+	if b.total > 0 {
+		fraction = min(float64(done)/float64(b.total), 1)
+	}
+
+	filled := int(fraction * PROGRESS_BAR_WIDTH)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", PROGRESS_BAR_WIDTH-filled)
+
+	elapsed := time.Since(b.start)
+	eta := "?"
+	if done > 0 {
+		eta = time.Duration(float64(elapsed) / float64(done) * float64(b.total-done)).Round(time.Second).String()
+	}
+
+	fmt.Fprintf(b.out, "\r\033[K%s [%s] %3.0f%% %.2f MB/s ETA %s %s", b.label, bar, fraction*100, throughput(int(done), elapsed), eta, b.stage)
+	b.drawn = time.Now()
+	b.hidden = false
+}
+
+// removes the bar from its line so a message can be printed, the next update draws it again
+func (b *progressBar) clear() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.hidden {
+		fmt.Fprint(b.out, "\r\033[K")
+		b.hidden = true
+	}
+}
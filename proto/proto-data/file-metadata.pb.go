@@ -22,13 +22,30 @@ const (
 )
 
 type CompressedFileMetaData struct {
-	state         protoimpl.MessageState              `protogen:"open.v1"`
-	EncodedLen    int64                               `protobuf:"varint,1,opt,name=EncodedLen,proto3" json:"EncodedLen,omitempty"`
-	PaddingSize   int32                               `protobuf:"varint,2,opt,name=PaddingSize,proto3" json:"PaddingSize,omitempty"`
-	OriginalSize  int64                               `protobuf:"varint,3,opt,name=OriginalSize,proto3" json:"OriginalSize,omitempty"`
-	BwtIdx        int32                               `protobuf:"varint,4,opt,name=BwtIdx,proto3" json:"BwtIdx,omitempty"`
-	Frequencies   []*CompressedFileMetaData_Frequency `protobuf:"bytes,5,rep,name=Frequencies,proto3" json:"Frequencies,omitempty"`
-	RleDict       []int32                             `protobuf:"varint,6,rep,packed,name=RleDict,proto3" json:"RleDict,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// version 0 files are a single block described by the fields below. newer versions describe their content
+	// in Blocks and only fill in OriginalSize and EncodedLen as the totals of all blocks, the rest is left empty
+	EncodedLen   int64                               `protobuf:"varint,1,opt,name=EncodedLen,proto3" json:"EncodedLen,omitempty"`
+	PaddingSize  int32                               `protobuf:"varint,2,opt,name=PaddingSize,proto3" json:"PaddingSize,omitempty"`
+	OriginalSize int64                               `protobuf:"varint,3,opt,name=OriginalSize,proto3" json:"OriginalSize,omitempty"`
+	BwtIdx       int32                               `protobuf:"varint,4,opt,name=BwtIdx,proto3" json:"BwtIdx,omitempty"`
+	Frequencies  []*CompressedFileMetaData_Frequency `protobuf:"bytes,5,rep,name=Frequencies,proto3" json:"Frequencies,omitempty"`
+	RleDict      []int32                             `protobuf:"varint,6,rep,packed,name=RleDict,proto3" json:"RleDict,omitempty"`
+	Version      uint32                              `protobuf:"varint,7,opt,name=Version,proto3" json:"Version,omitempty"`
+	// crc32 of the whole original input
+	Checksum uint32                          `protobuf:"varint,8,opt,name=Checksum,proto3" json:"Checksum,omitempty"`
+	Blocks   []*CompressedFileMetaData_Block `protobuf:"bytes,9,rep,name=Blocks,proto3" json:"Blocks,omitempty"`
+	Entries  []*CompressedFileMetaData_Entry `protobuf:"bytes,10,rep,name=Entries,proto3" json:"Entries,omitempty"`
+	// single compressed files remember where they came from so decoding can restore them,
+	// Name is only the base name and empty when compressed from stdin
+	Name string `protobuf:"bytes,11,opt,name=Name,proto3" json:"Name,omitempty"`
+	// go fs.FileMode permission bits
+	Mode uint32 `protobuf:"varint,12,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	// unix nanoseconds
+	ModTime int64 `protobuf:"varint,13,opt,name=ModTime,proto3" json:"ModTime,omitempty"`
+	// id of the dictionary some blocks are coded with, 0 when there is none
+	DictionaryId  uint32                             `protobuf:"varint,14,opt,name=DictionaryId,proto3" json:"DictionaryId,omitempty"`
+	Encrypted     *CompressedFileMetaData_Encryption `protobuf:"bytes,15,opt,name=Encrypted,proto3" json:"Encrypted,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -105,6 +122,208 @@ func (x *CompressedFileMetaData) GetRleDict() []int32 {
 	return nil
 }
 
+func (x *CompressedFileMetaData) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData) GetChecksum() uint32 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData) GetBlocks() []*CompressedFileMetaData_Block {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData) GetEntries() []*CompressedFileMetaData_Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CompressedFileMetaData) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData) GetDictionaryId() uint32 {
+	if x != nil {
+		return x.DictionaryId
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData) GetEncrypted() *CompressedFileMetaData_Encryption {
+	if x != nil {
+		return x.Encrypted
+	}
+	return nil
+}
+
+// trained from sample files so small inputs do not have to store their own frequency tables,
+// every table has a frequency for all 256 symbols so anything can be coded with it
+type Dictionary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// crc32 of the dictionary marshaled without its id
+	Id uint32 `protobuf:"varint,1,opt,name=Id,proto3" json:"Id,omitempty"`
+	// frequencies of raw bytes for the huffman only levels
+	Raw []*CompressedFileMetaData_Frequency `protobuf:"bytes,2,rep,name=Raw,proto3" json:"Raw,omitempty"`
+	// frequencies of the bwt + rle + mft output for the other levels
+	Transformed   []*CompressedFileMetaData_Frequency `protobuf:"bytes,3,rep,name=Transformed,proto3" json:"Transformed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dictionary) Reset() {
+	*x = Dictionary{}
+	mi := &file_proto_file_metadata_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dictionary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dictionary) ProtoMessage() {}
+
+func (x *Dictionary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dictionary.ProtoReflect.Descriptor instead.
+func (*Dictionary) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Dictionary) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Dictionary) GetRaw() []*CompressedFileMetaData_Frequency {
+	if x != nil {
+		return x.Raw
+	}
+	return nil
+}
+
+func (x *Dictionary) GetTransformed() []*CompressedFileMetaData_Frequency {
+	if x != nil {
+		return x.Transformed
+	}
+	return nil
+}
+
+// reed solomon parity of everything before it, the meta size, metadata and block data, split into shards.
+// every group of up to 100 data shards gets Percent parity shards. it is stored after the block data,
+// followed by this record, its length, crc32 and a magic so it can be found without the metadata
+type RecoveryRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the last data shard is padded with zeros to this size
+	ShardSize uint32 `protobuf:"varint,1,opt,name=ShardSize,proto3" json:"ShardSize,omitempty"`
+	Percent   uint32 `protobuf:"varint,2,opt,name=Percent,proto3" json:"Percent,omitempty"`
+	// bytes from the start of the container the parity covers
+	ProtectedLen int64 `protobuf:"varint,3,opt,name=ProtectedLen,proto3" json:"ProtectedLen,omitempty"`
+	// crc32 of every data shard followed by every parity shard, damaged shards are found with these
+	ShardChecksums []uint32 `protobuf:"fixed32,4,rep,packed,name=ShardChecksums,proto3" json:"ShardChecksums,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RecoveryRecord) Reset() {
+	*x = RecoveryRecord{}
+	mi := &file_proto_file_metadata_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecoveryRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecoveryRecord) ProtoMessage() {}
+
+func (x *RecoveryRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecoveryRecord.ProtoReflect.Descriptor instead.
+func (*RecoveryRecord) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RecoveryRecord) GetShardSize() uint32 {
+	if x != nil {
+		return x.ShardSize
+	}
+	return 0
+}
+
+func (x *RecoveryRecord) GetPercent() uint32 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *RecoveryRecord) GetProtectedLen() int64 {
+	if x != nil {
+		return x.ProtectedLen
+	}
+	return 0
+}
+
+func (x *RecoveryRecord) GetShardChecksums() []uint32 {
+	if x != nil {
+		return x.ShardChecksums
+	}
+	return nil
+}
+
 type CompressedFileMetaData_Frequency struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Char          []byte                 `protobuf:"bytes,1,opt,name=Char,proto3" json:"Char,omitempty"`
@@ -115,7 +334,7 @@ type CompressedFileMetaData_Frequency struct {
 
 func (x *CompressedFileMetaData_Frequency) Reset() {
 	*x = CompressedFileMetaData_Frequency{}
-	mi := &file_proto_file_metadata_proto_msgTypes[1]
+	mi := &file_proto_file_metadata_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -127,7 +346,7 @@ func (x *CompressedFileMetaData_Frequency) String() string {
 func (*CompressedFileMetaData_Frequency) ProtoMessage() {}
 
 func (x *CompressedFileMetaData_Frequency) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_file_metadata_proto_msgTypes[1]
+	mi := &file_proto_file_metadata_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -157,11 +376,393 @@ func (x *CompressedFileMetaData_Frequency) GetFrequency() int32 {
 	return 0
 }
 
+// blocks with a single distinct symbol have no encoded bits, their only Frequencies
+// entry is a run header giving the symbol and how many times it repeats
+type CompressedFileMetaData_Block struct {
+	state        protoimpl.MessageState              `protogen:"open.v1"`
+	EncodedLen   int64                               `protobuf:"varint,1,opt,name=EncodedLen,proto3" json:"EncodedLen,omitempty"`
+	PaddingSize  int32                               `protobuf:"varint,2,opt,name=PaddingSize,proto3" json:"PaddingSize,omitempty"`
+	OriginalSize int64                               `protobuf:"varint,3,opt,name=OriginalSize,proto3" json:"OriginalSize,omitempty"`
+	BwtIdx       int32                               `protobuf:"varint,4,opt,name=BwtIdx,proto3" json:"BwtIdx,omitempty"`
+	Frequencies  []*CompressedFileMetaData_Frequency `protobuf:"bytes,5,rep,name=Frequencies,proto3" json:"Frequencies,omitempty"`
+	RleDict      []int32                             `protobuf:"varint,6,rep,packed,name=RleDict,proto3" json:"RleDict,omitempty"`
+	// crc32 of the original block content
+	Checksum uint32 `protobuf:"varint,7,opt,name=Checksum,proto3" json:"Checksum,omitempty"`
+	// fast compression levels huffman code the raw bytes without bwt, rle and mft,
+	// BwtIdx and RleDict are unused then
+	HuffmanOnly bool `protobuf:"varint,8,opt,name=HuffmanOnly,proto3" json:"HuffmanOnly,omitempty"`
+	// coded with the table of the dictionary the file was compressed with, Frequencies is empty then
+	DictionaryTable bool `protobuf:"varint,9,opt,name=DictionaryTable,proto3" json:"DictionaryTable,omitempty"`
+	// id of the built in huffman table the block is coded with, 0 when it is not, Frequencies is empty then
+	StaticTable uint32 `protobuf:"varint,10,opt,name=StaticTable,proto3" json:"StaticTable,omitempty"`
+	// higher levels code blocks with several of their own tables, Frequencies is the first one and these are the rest.
+	// the coded symbols switch tables every 50, Selectors has the index of the table of every group of 50
+	Tables        []*CompressedFileMetaData_Block_Table `protobuf:"bytes,11,rep,name=Tables,proto3" json:"Tables,omitempty"`
+	Selectors     []byte                                `protobuf:"bytes,12,opt,name=Selectors,proto3" json:"Selectors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressedFileMetaData_Block) Reset() {
+	*x = CompressedFileMetaData_Block{}
+	mi := &file_proto_file_metadata_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressedFileMetaData_Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressedFileMetaData_Block) ProtoMessage() {}
+
+func (x *CompressedFileMetaData_Block) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressedFileMetaData_Block.ProtoReflect.Descriptor instead.
+func (*CompressedFileMetaData_Block) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{0, 1}
+}
+
+func (x *CompressedFileMetaData_Block) GetEncodedLen() int64 {
+	if x != nil {
+		return x.EncodedLen
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetPaddingSize() int32 {
+	if x != nil {
+		return x.PaddingSize
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetOriginalSize() int64 {
+	if x != nil {
+		return x.OriginalSize
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetBwtIdx() int32 {
+	if x != nil {
+		return x.BwtIdx
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetFrequencies() []*CompressedFileMetaData_Frequency {
+	if x != nil {
+		return x.Frequencies
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Block) GetRleDict() []int32 {
+	if x != nil {
+		return x.RleDict
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Block) GetChecksum() uint32 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetHuffmanOnly() bool {
+	if x != nil {
+		return x.HuffmanOnly
+	}
+	return false
+}
+
+func (x *CompressedFileMetaData_Block) GetDictionaryTable() bool {
+	if x != nil {
+		return x.DictionaryTable
+	}
+	return false
+}
+
+func (x *CompressedFileMetaData_Block) GetStaticTable() uint32 {
+	if x != nil {
+		return x.StaticTable
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Block) GetTables() []*CompressedFileMetaData_Block_Table {
+	if x != nil {
+		return x.Tables
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Block) GetSelectors() []byte {
+	if x != nil {
+		return x.Selectors
+	}
+	return nil
+}
+
+// archives store the content of every regular file one after another,
+// entries tell where each file is in the decoded content
+type CompressedFileMetaData_Entry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// slash separated and relative to the archive root
+	Path string `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Size int64  `protobuf:"varint,2,opt,name=Size,proto3" json:"Size,omitempty"`
+	// go fs.FileMode bits, including the dir and symlink type bits
+	Mode uint32 `protobuf:"varint,3,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	// unix nanoseconds
+	ModTime       int64  `protobuf:"varint,4,opt,name=ModTime,proto3" json:"ModTime,omitempty"`
+	LinkTarget    string `protobuf:"bytes,5,opt,name=LinkTarget,proto3" json:"LinkTarget,omitempty"`
+	Offset        int64  `protobuf:"varint,6,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressedFileMetaData_Entry) Reset() {
+	*x = CompressedFileMetaData_Entry{}
+	mi := &file_proto_file_metadata_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressedFileMetaData_Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressedFileMetaData_Entry) ProtoMessage() {}
+
+func (x *CompressedFileMetaData_Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressedFileMetaData_Entry.ProtoReflect.Descriptor instead.
+func (*CompressedFileMetaData_Entry) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{0, 2}
+}
+
+func (x *CompressedFileMetaData_Entry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CompressedFileMetaData_Entry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Entry) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Entry) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Entry) GetLinkTarget() string {
+	if x != nil {
+		return x.LinkTarget
+	}
+	return ""
+}
+
+func (x *CompressedFileMetaData_Entry) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+// encrypted files only have this and OriginalSize in their metadata, the whole compressed file
+// follows split into chunks that are sealed with AES-256-GCM one by one
+type CompressedFileMetaData_Encryption struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// argon2id parameters the key is derived from the password with
+	Salt []byte `protobuf:"bytes,1,opt,name=Salt,proto3" json:"Salt,omitempty"`
+	Time uint32 `protobuf:"varint,2,opt,name=Time,proto3" json:"Time,omitempty"`
+	// KiB
+	Memory  uint32 `protobuf:"varint,3,opt,name=Memory,proto3" json:"Memory,omitempty"`
+	Threads uint32 `protobuf:"varint,4,opt,name=Threads,proto3" json:"Threads,omitempty"`
+	// nonces are this followed by the big endian chunk index
+	NoncePrefix []byte `protobuf:"bytes,5,opt,name=NoncePrefix,proto3" json:"NoncePrefix,omitempty"`
+	// plain bytes per chunk, every sealed chunk is 16 bytes longer
+	ChunkSize uint32 `protobuf:"varint,6,opt,name=ChunkSize,proto3" json:"ChunkSize,omitempty"`
+	// derived together with the key so a wrong password is told apart from corrupt data
+	KeyCheck []byte `protobuf:"bytes,7,opt,name=KeyCheck,proto3" json:"KeyCheck,omitempty"`
+	// crc32 of the header every chunk authenticates, a corrupt header is reported before the key check fails on it
+	HeaderChecksum uint32 `protobuf:"fixed32,8,opt,name=HeaderChecksum,proto3" json:"HeaderChecksum,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CompressedFileMetaData_Encryption) Reset() {
+	*x = CompressedFileMetaData_Encryption{}
+	mi := &file_proto_file_metadata_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressedFileMetaData_Encryption) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressedFileMetaData_Encryption) ProtoMessage() {}
+
+func (x *CompressedFileMetaData_Encryption) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressedFileMetaData_Encryption.ProtoReflect.Descriptor instead.
+func (*CompressedFileMetaData_Encryption) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{0, 3}
+}
+
+func (x *CompressedFileMetaData_Encryption) GetSalt() []byte {
+	if x != nil {
+		return x.Salt
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Encryption) GetTime() uint32 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Encryption) GetMemory() uint32 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Encryption) GetThreads() uint32 {
+	if x != nil {
+		return x.Threads
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Encryption) GetNoncePrefix() []byte {
+	if x != nil {
+		return x.NoncePrefix
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Encryption) GetChunkSize() uint32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+func (x *CompressedFileMetaData_Encryption) GetKeyCheck() []byte {
+	if x != nil {
+		return x.KeyCheck
+	}
+	return nil
+}
+
+func (x *CompressedFileMetaData_Encryption) GetHeaderChecksum() uint32 {
+	if x != nil {
+		return x.HeaderChecksum
+	}
+	return 0
+}
+
+type CompressedFileMetaData_Block_Table struct {
+	state         protoimpl.MessageState              `protogen:"open.v1"`
+	Frequencies   []*CompressedFileMetaData_Frequency `protobuf:"bytes,1,rep,name=Frequencies,proto3" json:"Frequencies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressedFileMetaData_Block_Table) Reset() {
+	*x = CompressedFileMetaData_Block_Table{}
+	mi := &file_proto_file_metadata_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressedFileMetaData_Block_Table) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressedFileMetaData_Block_Table) ProtoMessage() {}
+
+func (x *CompressedFileMetaData_Block_Table) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_file_metadata_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressedFileMetaData_Block_Table.ProtoReflect.Descriptor instead.
+func (*CompressedFileMetaData_Block_Table) Descriptor() ([]byte, []int) {
+	return file_proto_file_metadata_proto_rawDescGZIP(), []int{0, 1, 0}
+}
+
+func (x *CompressedFileMetaData_Block_Table) GetFrequencies() []*CompressedFileMetaData_Frequency {
+	if x != nil {
+		return x.Frequencies
+	}
+	return nil
+}
+
 var File_proto_file_metadata_proto protoreflect.FileDescriptor
 
 const file_proto_file_metadata_proto_rawDesc = "" +
 	"\n" +
-	"\x19proto/file-metadata.proto\x12\x05proto\"\xba\x02\n" +
+	"\x19proto/file-metadata.proto\x12\x05proto\"\xcb\f\n" +
 	"\x16CompressedFileMetaData\x12\x1e\n" +
 	"\n" +
 	"EncodedLen\x18\x01 \x01(\x03R\n" +
@@ -170,10 +771,67 @@ const file_proto_file_metadata_proto_rawDesc = "" +
 	"\fOriginalSize\x18\x03 \x01(\x03R\fOriginalSize\x12\x16\n" +
 	"\x06BwtIdx\x18\x04 \x01(\x05R\x06BwtIdx\x12I\n" +
 	"\vFrequencies\x18\x05 \x03(\v2'.proto.CompressedFileMetaData.FrequencyR\vFrequencies\x12\x18\n" +
-	"\aRleDict\x18\x06 \x03(\x05R\aRleDict\x1a=\n" +
+	"\aRleDict\x18\x06 \x03(\x05R\aRleDict\x12\x18\n" +
+	"\aVersion\x18\a \x01(\rR\aVersion\x12\x1a\n" +
+	"\bChecksum\x18\b \x01(\rR\bChecksum\x12;\n" +
+	"\x06Blocks\x18\t \x03(\v2#.proto.CompressedFileMetaData.BlockR\x06Blocks\x12=\n" +
+	"\aEntries\x18\n" +
+	" \x03(\v2#.proto.CompressedFileMetaData.EntryR\aEntries\x12\x12\n" +
+	"\x04Name\x18\v \x01(\tR\x04Name\x12\x12\n" +
+	"\x04Mode\x18\f \x01(\rR\x04Mode\x12\x18\n" +
+	"\aModTime\x18\r \x01(\x03R\aModTime\x12\"\n" +
+	"\fDictionaryId\x18\x0e \x01(\rR\fDictionaryId\x12F\n" +
+	"\tEncrypted\x18\x0f \x01(\v2(.proto.CompressedFileMetaData.EncryptionR\tEncrypted\x1a=\n" +
 	"\tFrequency\x12\x12\n" +
 	"\x04Char\x18\x01 \x01(\fR\x04Char\x12\x1c\n" +
-	"\tFrequency\x18\x02 \x01(\x05R\tFrequencyB\x12Z\x10proto/proto-datab\x06proto3"
+	"\tFrequency\x18\x02 \x01(\x05R\tFrequency\x1a\xa9\x04\n" +
+	"\x05Block\x12\x1e\n" +
+	"\n" +
+	"EncodedLen\x18\x01 \x01(\x03R\n" +
+	"EncodedLen\x12 \n" +
+	"\vPaddingSize\x18\x02 \x01(\x05R\vPaddingSize\x12\"\n" +
+	"\fOriginalSize\x18\x03 \x01(\x03R\fOriginalSize\x12\x16\n" +
+	"\x06BwtIdx\x18\x04 \x01(\x05R\x06BwtIdx\x12I\n" +
+	"\vFrequencies\x18\x05 \x03(\v2'.proto.CompressedFileMetaData.FrequencyR\vFrequencies\x12\x18\n" +
+	"\aRleDict\x18\x06 \x03(\x05R\aRleDict\x12\x1a\n" +
+	"\bChecksum\x18\a \x01(\rR\bChecksum\x12 \n" +
+	"\vHuffmanOnly\x18\b \x01(\bR\vHuffmanOnly\x12(\n" +
+	"\x0fDictionaryTable\x18\t \x01(\bR\x0fDictionaryTable\x12 \n" +
+	"\vStaticTable\x18\n" +
+	" \x01(\rR\vStaticTable\x12A\n" +
+	"\x06Tables\x18\v \x03(\v2).proto.CompressedFileMetaData.Block.TableR\x06Tables\x12\x1c\n" +
+	"\tSelectors\x18\f \x01(\fR\tSelectors\x1aR\n" +
+	"\x05Table\x12I\n" +
+	"\vFrequencies\x18\x01 \x03(\v2'.proto.CompressedFileMetaData.FrequencyR\vFrequencies\x1a\x95\x01\n" +
+	"\x05Entry\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Size\x18\x02 \x01(\x03R\x04Size\x12\x12\n" +
+	"\x04Mode\x18\x03 \x01(\rR\x04Mode\x12\x18\n" +
+	"\aModTime\x18\x04 \x01(\x03R\aModTime\x12\x1e\n" +
+	"\n" +
+	"LinkTarget\x18\x05 \x01(\tR\n" +
+	"LinkTarget\x12\x16\n" +
+	"\x06Offset\x18\x06 \x01(\x03R\x06Offset\x1a\xea\x01\n" +
+	"\n" +
+	"Encryption\x12\x12\n" +
+	"\x04Salt\x18\x01 \x01(\fR\x04Salt\x12\x12\n" +
+	"\x04Time\x18\x02 \x01(\rR\x04Time\x12\x16\n" +
+	"\x06Memory\x18\x03 \x01(\rR\x06Memory\x12\x18\n" +
+	"\aThreads\x18\x04 \x01(\rR\aThreads\x12 \n" +
+	"\vNoncePrefix\x18\x05 \x01(\fR\vNoncePrefix\x12\x1c\n" +
+	"\tChunkSize\x18\x06 \x01(\rR\tChunkSize\x12\x1a\n" +
+	"\bKeyCheck\x18\a \x01(\fR\bKeyCheck\x12&\n" +
+	"\x0eHeaderChecksum\x18\b \x01(\aR\x0eHeaderChecksum\"\xa2\x01\n" +
+	"\n" +
+	"Dictionary\x12\x0e\n" +
+	"\x02Id\x18\x01 \x01(\rR\x02Id\x129\n" +
+	"\x03Raw\x18\x02 \x03(\v2'.proto.CompressedFileMetaData.FrequencyR\x03Raw\x12I\n" +
+	"\vTransformed\x18\x03 \x03(\v2'.proto.CompressedFileMetaData.FrequencyR\vTransformed\"\x94\x01\n" +
+	"\x0eRecoveryRecord\x12\x1c\n" +
+	"\tShardSize\x18\x01 \x01(\rR\tShardSize\x12\x18\n" +
+	"\aPercent\x18\x02 \x01(\rR\aPercent\x12\"\n" +
+	"\fProtectedLen\x18\x03 \x01(\x03R\fProtectedLen\x12&\n" +
+	"\x0eShardChecksums\x18\x04 \x03(\aR\x0eShardChecksumsB\x12Z\x10proto/proto-datab\x06proto3"
 
 var (
 	file_proto_file_metadata_proto_rawDescOnce sync.Once
@@ -187,18 +845,32 @@ func file_proto_file_metadata_proto_rawDescGZIP() []byte {
 	return file_proto_file_metadata_proto_rawDescData
 }
 
-var file_proto_file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_proto_file_metadata_proto_goTypes = []any{
-	(*CompressedFileMetaData)(nil),           // 0: proto.CompressedFileMetaData
-	(*CompressedFileMetaData_Frequency)(nil), // 1: proto.CompressedFileMetaData.Frequency
+	(*CompressedFileMetaData)(nil),             // 0: proto.CompressedFileMetaData
+	(*Dictionary)(nil),                         // 1: proto.Dictionary
+	(*RecoveryRecord)(nil),                     // 2: proto.RecoveryRecord
+	(*CompressedFileMetaData_Frequency)(nil),   // 3: proto.CompressedFileMetaData.Frequency
+	(*CompressedFileMetaData_Block)(nil),       // 4: proto.CompressedFileMetaData.Block
+	(*CompressedFileMetaData_Entry)(nil),       // 5: proto.CompressedFileMetaData.Entry
+	(*CompressedFileMetaData_Encryption)(nil),  // 6: proto.CompressedFileMetaData.Encryption
+	(*CompressedFileMetaData_Block_Table)(nil), // 7: proto.CompressedFileMetaData.Block.Table
 }
 var file_proto_file_metadata_proto_depIdxs = []int32{
-	1, // 0: proto.CompressedFileMetaData.Frequencies:type_name -> proto.CompressedFileMetaData.Frequency
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	3, // 0: proto.CompressedFileMetaData.Frequencies:type_name -> proto.CompressedFileMetaData.Frequency
+	4, // 1: proto.CompressedFileMetaData.Blocks:type_name -> proto.CompressedFileMetaData.Block
+	5, // 2: proto.CompressedFileMetaData.Entries:type_name -> proto.CompressedFileMetaData.Entry
+	6, // 3: proto.CompressedFileMetaData.Encrypted:type_name -> proto.CompressedFileMetaData.Encryption
+	3, // 4: proto.Dictionary.Raw:type_name -> proto.CompressedFileMetaData.Frequency
+	3, // 5: proto.Dictionary.Transformed:type_name -> proto.CompressedFileMetaData.Frequency
+	3, // 6: proto.CompressedFileMetaData.Block.Frequencies:type_name -> proto.CompressedFileMetaData.Frequency
+	7, // 7: proto.CompressedFileMetaData.Block.Tables:type_name -> proto.CompressedFileMetaData.Block.Table
+	3, // 8: proto.CompressedFileMetaData.Block.Table.Frequencies:type_name -> proto.CompressedFileMetaData.Frequency
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_proto_file_metadata_proto_init() }
@@ -212,7 +884,7 @@ func file_proto_file_metadata_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_file_metadata_proto_rawDesc), len(file_proto_file_metadata_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,41 @@
+package reader
+
+import (
+	"bytes"
+	"stinky-compression/benchdata"
+	"testing"
+)
+
+func TestReadBitStopsBeforePadding(t *testing.T) {
+	encoded := []byte{0b10111110, 0b10000000}
+	reader := NewBitReader(bytes.NewReader(encoded), int64(len(encoded)), 7)
+
+	read := []byte{}
+	for reader.Next() {
+		bit, err := reader.ReadBit()
+		if err != nil {
+			t.Fatalf("ReadBit: %+v", err)
+		}
+
+		if bit != END_OF_READING {
+			read = append(read, '0'+bit)
+		}
+	}
+
+	if string(read) != "101111101" {
+		t.Fatalf("unexpected bits %s", read)
+	}
+}
+
+func BenchmarkReadBit(b *testing.B) {
+	benchdata.Run(b, 64*benchdata.MB, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			reader := NewBitReader(bytes.NewReader(input), int64(len(input)), 0)
+			for reader.Next() {
+				if _, err := reader.ReadBit(); err != nil {
+					b.Fatalf("ReadBit: %+v", err)
+				}
+			}
+		}
+	})
+}
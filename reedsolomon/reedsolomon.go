@@ -0,0 +1,241 @@
+package reedsolomon
+
+import (
+	"fmt"
+	sCError "stinky-compression/error"
+)
+
+// systematic erasure code over GF(2^8), data shards are kept as they are and every parity shard is a
+// combination of them taken from a cauchy matrix. any square part of a cauchy matrix can be inverted,
+// so any dataShards of the data and parity shards together are enough to rebuild all of them
+
+// data and parity shards together, shard indexes have to fit in a field element
+const MAX_SHARDS = 256
+
+// x^8 + x^4 + x^3 + x^2 + 1
+const fieldPolynomial = 0x11d
+
+var (
+	// doubled so the sum of two logs never has to be reduced
+	expTable [2 * 255]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := 1
+	for idx := range 255 {
+		expTable[idx] = byte(x)
+		expTable[idx+255] = byte(x)
+		logTable[x] = byte(idx)
+
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= fieldPolynomial
+		}
+	}
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func inv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+// dst ^= c * src
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+
+	products := [256]byte{}
+	for value := range 256 {
+		products[value] = mul(c, byte(value))
+	}
+
+	for idx, bt := range src {
+		dst[idx] ^= products[bt]
+	}
+}
+
+// row of the generator matrix that gives shard idx from the data shards
+func matrixRow(idx, dataShards int) []byte {
+	row := make([]byte, dataShards)
+	if idx < dataShards {
+		row[idx] = 1
+		return row
+	}
+
+	// parity rows use idx and the data columns use their own index, they never meet so nothing is divided by 0
+	for col := range row {
+		row[col] = inv(byte(idx) ^ byte(col))
+	}
+
+	return row
+}
+
+func checkShardCounts(dataShards, parityShards int) error {
+	if dataShards < 1 || parityShards < 0 || dataShards+parityShards > MAX_SHARDS {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("%d data and %d parity shards, at most %d shards are supported", dataShards, parityShards, MAX_SHARDS),
+		}
+	}
+
+	return nil
+}
+
+// Encode returns parityShards parity shards for data, all data shards have to be the same size
+func Encode(data [][]byte, parityShards int) ([][]byte, error) {
+	if err := checkShardCounts(len(data), parityShards); err != nil {
+		return nil, err
+	}
+
+	shardSize := len(data[0])
+	for idx, shard := range data {
+		if len(shard) != shardSize {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("data shard %d has %d bytes, expected %d", idx, len(shard), shardSize),
+			}
+		}
+	}
+
+	parity := make([][]byte, parityShards)
+	for idx := range parity {
+		parity[idx] = make([]byte, shardSize)
+		for col, coefficient := range matrixRow(len(data)+idx, len(data)) {
+			mulAdd(parity[idx], data[col], coefficient)
+		}
+	}
+
+	return parity, nil
+}
+
+// gauss jordan elimination, matrix is used up
+func invert(matrix [][]byte) ([][]byte, error) {
+	size := len(matrix)
+	inverse := make([][]byte, size)
+	for idx := range inverse {
+		inverse[idx] = make([]byte, size)
+		inverse[idx][idx] = 1
+	}
+
+	for col := range size {
+		pivot := col
+		for pivot < size && matrix[pivot][col] == 0 {
+			pivot++
+		}
+
+		if pivot == size {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  "shard matrix can not be inverted",
+			}
+		}
+
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		inverse[col], inverse[pivot] = inverse[pivot], inverse[col]
+
+		scale := inv(matrix[col][col])
+		for idx := range size {
+			matrix[col][idx] = mul(matrix[col][idx], scale)
+			inverse[col][idx] = mul(inverse[col][idx], scale)
+		}
+
+		for row := range size {
+			if row == col || matrix[row][col] == 0 {
+				continue
+			}
+
+			factor := matrix[row][col]
+			mulAdd(matrix[row], matrix[col], factor)
+			mulAdd(inverse[row], inverse[col], factor)
+		}
+	}
+
+	return inverse, nil
+}
+
+// Reconstruct fills in the missing (nil) shards of shards, the first dataShards of them are data shards
+// and the rest parity shards as returned by Encode. it fails when fewer than dataShards shards are left
+func Reconstruct(shards [][]byte, dataShards int) error {
+	if err := checkShardCounts(dataShards, len(shards)-dataShards); err != nil {
+		return err
+	}
+
+	present := []int{}
+	shardSize := -1
+	for idx, shard := range shards {
+		if shard == nil {
+			continue
+		}
+
+		if shardSize >= 0 && len(shard) != shardSize {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("shard %d has %d bytes, expected %d", idx, len(shard), shardSize),
+			}
+		}
+
+		shardSize = len(shard)
+		present = append(present, idx)
+	}
+
+	if len(present) == len(shards) {
+		return nil
+	}
+
+	if len(present) < dataShards {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("%d of %d shards are missing, at most %d can be rebuilt", len(shards)-len(present), len(shards), len(shards)-dataShards),
+		}
+	}
+
+	// the rows of any dataShards shards that are left give them from the data, inverted they give the data from them
+	present = present[:dataShards]
+	matrix := make([][]byte, dataShards)
+	for row, idx := range present {
+		matrix[row] = matrixRow(idx, dataShards)
+	}
+
+	inverse, err := invert(matrix)
+	if err != nil {
+		return err
+	}
+
+	for idx := range dataShards {
+		if shards[idx] != nil {
+			continue
+		}
+
+		shards[idx] = make([]byte, shardSize)
+		for col, coefficient := range inverse[idx] {
+			mulAdd(shards[idx], shards[present[col]], coefficient)
+		}
+	}
+
+	for idx := dataShards; idx < len(shards); idx++ {
+		if shards[idx] != nil {
+			continue
+		}
+
+		shards[idx] = make([]byte, shardSize)
+		for col, coefficient := range matrixRow(idx, dataShards) {
+			mulAdd(shards[idx], shards[col], coefficient)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func helperShards(t *testing.T, dataShards, shardSize int) [][]byte {
+	random := rand.New(rand.NewSource(int64(dataShards)))
+	data := make([][]byte, dataShards)
+	for idx := range data {
+		data[idx] = make([]byte, shardSize)
+		random.Read(data[idx])
+	}
+
+	return data
+}
+
+func TestCanReconstructMissingShards(t *testing.T) {
+	cases := map[string]struct {
+		dataShards   int
+		parityShards int
+		missing      []int
+	}{
+		"one data shard":          {dataShards: 10, parityShards: 2, missing: []int{3}},
+		"as many as parity":       {dataShards: 10, parityShards: 3, missing: []int{0, 5, 9}},
+		"data and parity":         {dataShards: 10, parityShards: 3, missing: []int{1, 11}},
+		"only parity":             {dataShards: 4, parityShards: 2, missing: []int{4, 5}},
+		"single data shard":       {dataShards: 1, parityShards: 1, missing: []int{0}},
+		"all shards of the field": {dataShards: 200, parityShards: 56, missing: []int{0, 17, 199, 200, 255}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			data := helperShards(t, tc.dataShards, 64)
+			parity, err := Encode(data, tc.parityShards)
+			if err != nil {
+				t.Fatalf("Encode: %+v", err)
+			}
+
+			original := append(append([][]byte{}, data...), parity...)
+			shards := append([][]byte{}, original...)
+			for _, idx := range tc.missing {
+				shards[idx] = nil
+			}
+
+			if err := Reconstruct(shards, tc.dataShards); err != nil {
+				t.Fatalf("Reconstruct: %+v", err)
+			}
+
+			for idx := range shards {
+				if !bytes.Equal(shards[idx], original[idx]) {
+					t.Fatalf("shard %d was not rebuilt correctly", idx)
+				}
+			}
+		})
+	}
+}
+
+func TestReconstructFailsWithTooManyMissing(t *testing.T) {
+	data := helperShards(t, 5, 16)
+	parity, err := Encode(data, 2)
+	if err != nil {
+		t.Fatalf("Encode: %+v", err)
+	}
+
+	shards := append(append([][]byte{}, data...), parity...)
+	shards[0], shards[2], shards[6] = nil, nil, nil
+	if err := Reconstruct(shards, 5); err == nil {
+		t.Fatalf("expected error rebuilding 3 shards from 2 parity shards")
+	}
+}
+
+func TestEncodeRejectsInvalidShards(t *testing.T) {
+	if _, err := Encode([][]byte{{1, 2}, {3}}, 1); err == nil {
+		t.Fatalf("expected error for shards of different sizes")
+	}
+
+	if _, err := Encode(helperShards(t, 200, 1), 57); err == nil {
+		t.Fatalf("expected error for more shards than the field has elements")
+	}
+}
@@ -1,5 +1,10 @@
 package rle
 
+import (
+	"fmt"
+	sCError "stinky-compression/error"
+)
+
 func Rle(input []byte) ([]byte, []int32) {
 	idxDict := []int32{}
 	encoded := []byte{}
@@ -28,18 +33,47 @@ func Rle(input []byte) ([]byte, []int32) {
 	return encoded, idxDict
 }
 
-func DecodeRle(input []byte, decodeDict []int32) []byte {
-	if len(decodeDict) == 0 {
-		return input
+func DecodeRle(input []byte, decodeDict []int32) ([]byte, error) {
+	return DecodeRleWithLimit(input, decodeDict, 0)
+}
+
+// DecodeRleWithLimit is DecodeRle refusing to expand to more than maxSize bytes, 0 means no limit.
+// every count is checked before anything is allocated so a single huge count can not exhaust memory
+func DecodeRleWithLimit(input []byte, decodeDict []int32, maxSize int64) ([]byte, error) {
+	if len(input) != len(decodeDict) {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("rle dict has %d counts for %d chars", len(decodeDict), len(input)),
+		}
+	}
+
+	size := int64(0)
+	for idx, count := range decodeDict {
+		if count <= 0 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("invalid rle count %d at %d", count, idx),
+			}
+		}
+
+		size += int64(count)
+		if maxSize > 0 && size > maxSize {
+			return nil, &sCError.LimitError{
+				Limit:  sCError.LIMIT_OUTPUT_SIZE,
+				Needed: size,
+				Max:    maxSize,
+			}
+		}
 	}
 
-	decoded := []byte{}
+	decoded := make([]byte, 0, size)
 	for idx, char := range input {
-		count := decodeDict[idx]
-		for c := 0; c < int(count); c++ {
+		for c := 0; c < int(decodeDict[idx]); c++ {
 			decoded = append(decoded, char)
 		}
 	}
 
-	return decoded
+	return decoded, nil
 }
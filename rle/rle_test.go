@@ -1,12 +1,21 @@
 package rle
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"stinky-compression/benchdata"
+	sCError "stinky-compression/error"
+	"testing"
+)
 
 func TestCanRLEEncodeAndDecode(t *testing.T) {
 	input := "WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW"
 	asBytes := []byte(input)
 	encoded, dict := Rle(asBytes)
-	decoded := DecodeRle(encoded, dict)
+	decoded, err := DecodeRle(encoded, dict)
+	if err != nil {
+		t.Fatalf("DecodeRle: %+v", err)
+	}
 
 	if len(decoded) != len(asBytes) {
 		t.Fatalf("decoded bytes len did not match input bytes len, wanted %d got %d", len(asBytes), len(decoded))
@@ -16,3 +25,94 @@ func TestCanRLEEncodeAndDecode(t *testing.T) {
 		t.Fatalf("decoded message did not match input\nwanted\n%s\ngot\n%s\n", input, string(decoded))
 	}
 }
+
+func TestDecodeRleRejectsInvalidDict(t *testing.T) {
+	cases := map[string]struct {
+		input []byte
+		dict  []int32
+	}{
+		"missing counts": {input: []byte("abc"), dict: []int32{1, 2}},
+		"extra counts":   {input: []byte("a"), dict: []int32{1, 2}},
+		"zero count":     {input: []byte("ab"), dict: []int32{1, 0}},
+		"negative count": {input: []byte("ab"), dict: []int32{-4, 1}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeRle(tc.input, tc.dict)
+			if err == nil {
+				t.Fatalf("expected error for input %q with dict %+v", tc.input, tc.dict)
+			}
+		})
+	}
+}
+
+func FuzzRle(f *testing.F) {
+	f.Add([]byte("WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		encoded, dict := Rle(input)
+		decoded, err := DecodeRle(encoded, dict)
+		if err != nil {
+			t.Fatalf("DecodeRle: %+v", err)
+		}
+
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("decoded message did not match input\nwanted\n%q\ngot\n%q\n", input, decoded)
+		}
+	})
+}
+
+func FuzzDecodeRle(f *testing.F) {
+	f.Add([]byte("WBW"), []byte{12, 1, 12})
+	f.Add([]byte("ab"), []byte{0, 0xff})
+
+	f.Fuzz(func(t *testing.T, input []byte, counts []byte) {
+		// every byte is a signed count so corrupt dicts with zero and negative counts come up, and stay small
+		dict := make([]int32, len(counts))
+		size := 0
+		for idx, count := range counts {
+			dict[idx] = int32(int8(count))
+			size += int(dict[idx])
+		}
+
+		decoded, err := DecodeRle(input, dict)
+		if err == nil && len(decoded) != size {
+			t.Fatalf("decoded %d bytes for counts adding up to %d", len(decoded), size)
+		}
+	})
+}
+
+func TestDecodeRleWithLimitStopsBeforeExpanding(t *testing.T) {
+	// would expand to 4 GB without the limit
+	_, err := DecodeRleWithLimit([]byte("ab"), []int32{1<<31 - 1, 1<<31 - 1}, 1024)
+
+	limitErr := &sCError.LimitError{}
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected LimitError, got %+v", err)
+	}
+
+	decoded, err := DecodeRleWithLimit([]byte("ab"), []int32{3, 2}, 5)
+	if err != nil || string(decoded) != "aaabb" {
+		t.Fatalf("unexpected decoded %q within the limit: %+v", decoded, err)
+	}
+}
+
+func BenchmarkRle(b *testing.B) {
+	benchdata.Run(b, 64*benchdata.MB, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			Rle(input)
+		}
+	})
+}
+
+func BenchmarkDecodeRle(b *testing.B) {
+	benchdata.Run(b, 64*benchdata.MB, func(b *testing.B, input []byte) {
+		encoded, dict := Rle(input)
+		for b.Loop() {
+			if _, err := DecodeRle(encoded, dict); err != nil {
+				b.Fatalf("DecodeRle: %+v", err)
+			}
+		}
+	})
+}
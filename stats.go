@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	STATS_TEXT = "text"
+	STATS_JSON = "json"
+)
+
+func validStatsFormat(format string) bool {
+	return format == "" || format == STATS_TEXT || format == STATS_JSON
+}
+
+// json prints a single line object per file so the stats of many files can be read as json lines
+func printStats(out io.Writer, name string, stats *stinkycompressor.Stats, format string) error {
+	if format == STATS_JSON {
+		return json.NewEncoder(out).Encode(struct {
+			File string `json:"file"`
+			*stinkycompressor.Stats
+		}{File: name, Stats: stats})
+	}
+
+	percent := func(part, whole int64) float64 {
+		if whole == 0 {
+			return 0
+		}
+
+		return float64(part) / float64(whole) * 100
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "file:\t%s\n", name)
+	fmt.Fprintf(tw, "original size:\t%d bytes\n", stats.OriginalSize)
+	fmt.Fprintf(tw, "compressed size:\t%d bytes (%.2f%%)\n", stats.CompressedSize, stats.Ratio*100)
+	fmt.Fprintf(tw, "encoded blocks:\t%d bytes\n", stats.EncodedSize)
+	fmt.Fprintf(tw, "overhead:\t%d bytes (%.2f%%), %d of metadata and %d of recovery\n", stats.Overhead, percent(stats.Overhead, stats.CompressedSize), stats.MetadataSize, stats.RecoverySize)
+	fmt.Fprintf(tw, "runs:\t%d before bwt, %d after\n", stats.InputRuns, stats.BwtRuns)
+	fmt.Fprintf(tw, "rle:\t%d bytes (%.2f%% of the bwt output), %d counts\n", stats.RleSize, stats.RleRatio*100, stats.RleDictLen)
+	fmt.Fprintf(tw, "mft zeros:\t%.2f%%\n", stats.MftZeroFraction*100)
+	fmt.Fprintf(tw, "entropy:\t%.3f bits/symbol over %d symbols\n", stats.Entropy, stats.Symbols)
+	fmt.Fprintf(tw, "huffman:\t%.3f bits/symbol\n", stats.BitsPerSymbol)
+	fmt.Fprintf(tw, "time:\tbwt %s, rle %s, mft %s, huffman %s, recovery %s, encrypt %s, total %s\n",
+		stats.BwtTime.Round(time.Microsecond), stats.RleTime.Round(time.Microsecond), stats.MftTime.Round(time.Microsecond),
+		stats.HuffmanTime.Round(time.Microsecond), stats.RecoveryTime.Round(time.Microsecond), stats.EncryptTime.Round(time.Microsecond),
+		stats.TotalTime.Round(time.Microsecond))
+	tw.Flush()
+
+	tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "block\toriginal\tbwt runs\trle\tmft zeros\tentropy\tbits/symbol\tencoded\ttable\ttime\t")
+	for idx, block := range stats.Blocks {
+		took := block.BwtTime + block.RleTime + block.MftTime + block.HuffmanTime
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%.2f%%\t%.3f\t%.3f\t%d\t%s\t%s\t\n", idx, block.OriginalSize, block.BwtRuns, block.RleSize,
+			block.MftZeroFraction*100, block.Entropy, block.BitsPerSymbol, block.EncodedSize, block.Table, took.Round(time.Microsecond))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,381 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"strings"
+	"time"
+)
+
+type ArchiveEntry = proto_data.CompressedFileMetaData_Entry
+
+// walks every path (directories recursively) and adds them to the archive under their base name,
+// like tar does, so "a/b/dir" ends up as "dir/..."
+func collectArchiveEntries(paths []string) ([]*ArchiveEntry, []byte, error) {
+	entries := []*ArchiveEntry{}
+	content := bytes.NewBuffer([]byte{})
+	seen := map[string]bool{}
+
+	for _, root := range paths {
+		parent := filepath.Dir(filepath.Clean(root))
+
+		err := filepath.WalkDir(root, func(walked string, dirEntry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(parent, walked)
+			if err != nil {
+				return err
+			}
+
+			name := filepath.ToSlash(rel)
+			if name == "." {
+				return nil
+			}
+
+			if seen[name] {
+				return &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.CODE_INVALID_ARGUMENT,
+					Message:  fmt.Sprintf("%s is added to the archive twice", name),
+				}
+			}
+			seen[name] = true
+
+			info, err := dirEntry.Info()
+			if err != nil {
+				return err
+			}
+
+			entry := &ArchiveEntry{
+				Path:    name,
+				Mode:    uint32(info.Mode()),
+				ModTime: info.ModTime().UnixNano(),
+				Offset:  int64(content.Len()),
+			}
+
+			switch {
+			case info.Mode()&fs.ModeSymlink != 0:
+				entry.LinkTarget, err = os.Readlink(walked)
+				if err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				fileContent, err := os.ReadFile(walked)
+				if err != nil {
+					return err
+				}
+
+				entry.Size = int64(len(fileContent))
+				content.Write(fileContent)
+			case !info.IsDir():
+				return &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.CODE_INVALID_ARGUMENT,
+					Message:  fmt.Sprintf("%s is not a regular file, directory or symlink", walked),
+				}
+			}
+
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			if _, ok := err.(*sCError.CompressorError); ok {
+				return nil, nil, err
+			}
+
+			return nil, nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  fmt.Sprintf("failed to add %s to archive", root),
+				Err:      err,
+			}
+		}
+	}
+
+	return entries, content.Bytes(), nil
+}
+
+// CompressArchive bundles files, directories and symlinks found under paths into a single compressed file
+func CompressArchive(paths []string, opts Options) ([]byte, error) {
+	return CompressArchiveContext(context.Background(), paths, opts)
+}
+
+// CompressArchiveContext compresses like CompressArchive and stops once ctx is done
+func CompressArchiveContext(ctx context.Context, paths []string, opts Options) ([]byte, error) {
+	entries, content, err := collectArchiveEntries(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return compressWithMetadata(ctx, content, opts, &proto_data.CompressedFileMetaData{
+		Entries: entries,
+	})
+}
+
+func IsArchive(metaR *proto_data.CompressedFileMetaData) bool {
+	return len(metaR.GetEntries()) > 0
+}
+
+// ListArchive returns the entries of an archive without decoding any of its content
+func ListArchive(content []byte) ([]*ArchiveEntry, error) {
+	metaR, err := ParseMetadata(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotEncrypted(metaR); err != nil {
+		return nil, err
+	}
+
+	if !IsArchive(metaR) {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file is not an archive",
+		}
+	}
+
+	return metaR.GetEntries(), nil
+}
+
+// member "dir" selects "dir" itself and everything under it
+func isSelected(entryPath string, members []string) bool {
+	if len(members) == 0 {
+		return true
+	}
+
+	for _, member := range members {
+		member = strings.TrimSuffix(path.Clean(member), "/")
+		if entryPath == member || strings.HasPrefix(entryPath, member+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateEntry(entry *ArchiveEntry, contentSize int64) error {
+	// refuses absolute paths and anything reaching outside of the destination through ".."
+	if !filepath.IsLocal(filepath.FromSlash(entry.GetPath())) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("archive entry %q points outside of the destination", entry.GetPath()),
+		}
+	}
+
+	if entry.GetOffset() < 0 || entry.GetSize() < 0 || entry.GetOffset() > contentSize-entry.GetSize() {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("archive entry %q does not fit in %d bytes of content", entry.GetPath(), contentSize),
+		}
+	}
+
+	return nil
+}
+
+func validateEntries(entries []*ArchiveEntry, contentSize int64) error {
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if err := validateEntry(entry, contentSize); err != nil {
+			return err
+		}
+
+		// a later entry of the same path would be written through whatever the first one created, e.g. a symlink
+		cleaned := path.Clean(entry.GetPath())
+		if seen[cleaned] {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("archive entry %q is in the archive twice", entry.GetPath()),
+			}
+		}
+		seen[cleaned] = true
+	}
+
+	return nil
+}
+
+// a symlink extracted earlier could otherwise redirect an entry outside of destDir
+func throughSymlink(destDir, entryPath string) bool {
+	parts := strings.Split(entryPath, "/")
+	current := destDir
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			// nothing exists here yet so nothing under it can be a symlink either
+			return false
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func extractEntry(entry *ArchiveEntry, content []byte, destDir string, force bool) error {
+	target := filepath.Join(destDir, filepath.FromSlash(entry.GetPath()))
+	mode := fs.FileMode(entry.GetMode())
+
+	if throughSymlink(destDir, entry.GetPath()) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("archive entry %q would be written through a symlink", entry.GetPath()),
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	// existing directories are extracted into, like tar does
+	if info, err := os.Lstat(target); err == nil && !(mode.IsDir() && info.IsDir()) {
+		if !force {
+			return outputExistsError(target)
+		}
+
+		// writing, chmod and chtimes would all follow a symlink already sitting at target
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case mode.IsDir():
+		// directories get their real mode once everything in them is written
+		return os.MkdirAll(target, 0o755)
+	case mode&fs.ModeSymlink != 0:
+		os.Remove(target)
+		return os.Symlink(entry.GetLinkTarget(), target)
+	case mode.IsRegular():
+		fileContent := content[entry.GetOffset() : entry.GetOffset()+entry.GetSize()]
+		if err := WriteFileAtomic(target, fileContent, force); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(target, mode.Perm()); err != nil {
+			return err
+		}
+
+		modTime := time.Unix(0, entry.GetModTime())
+		return os.Chtimes(target, modTime, modTime)
+	}
+
+	return &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_CORRUPT,
+		Message:  fmt.Sprintf("archive entry %q has unsupported mode %s", entry.GetPath(), mode),
+	}
+}
+
+// ExtractArchive decodes an archive and recreates its entries under destDir,
+// only the given members (and everything under them) are extracted when members is not empty.
+// Existing files are only replaced with force, opts limits decoding the archive content
+func ExtractArchive(content []byte, destDir string, members []string, force bool, opts DecodeOptions) ([]*ArchiveEntry, error) {
+	entries, err := ListArchive(content)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []*ArchiveEntry{}
+	for _, entry := range entries {
+		if isSelected(entry.GetPath(), members) {
+			selected = append(selected, entry)
+		}
+	}
+
+	for _, member := range members {
+		found := false
+		for _, entry := range entries {
+			found = found || isSelected(entry.GetPath(), []string{member})
+		}
+
+		if !found {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_INVALID_ARGUMENT,
+				Message:  fmt.Sprintf("%s is not in the archive", member),
+			}
+		}
+	}
+
+	decoded, err := DecodeWithOptions(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to create %s", destDir),
+			Err:      err,
+		}
+	}
+
+	if err := validateEntries(selected, int64(len(decoded))); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range selected {
+		if err := extractEntry(entry, decoded, destDir, force); err != nil {
+			if _, ok := err.(*sCError.CompressorError); ok {
+				return nil, err
+			}
+
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  fmt.Sprintf("failed to extract %s", entry.GetPath()),
+				Err:      err,
+			}
+		}
+	}
+
+	// children first so read only directories do not block anything written into them
+	for idx := len(selected) - 1; idx >= 0; idx-- {
+		entry := selected[idx]
+		mode := fs.FileMode(entry.GetMode())
+		if !mode.IsDir() {
+			continue
+		}
+
+		target := filepath.Join(destDir, filepath.FromSlash(entry.GetPath()))
+		modTime := time.Unix(0, entry.GetModTime())
+		if err := os.Chmod(target, mode.Perm()); err != nil {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  fmt.Sprintf("failed to set mode of %s", entry.GetPath()),
+				Err:      err,
+			}
+		}
+
+		if err := os.Chtimes(target, modTime, modTime); err != nil {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_IO,
+				Message:  fmt.Sprintf("failed to set modification time of %s", entry.GetPath()),
+				Err:      err,
+			}
+		}
+	}
+
+	return selected, nil
+}
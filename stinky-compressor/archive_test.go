@@ -0,0 +1,312 @@
+package stinkycompressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"testing"
+	"time"
+)
+
+func helperWriteTree(t *testing.T) string {
+	root := filepath.Join(t.TempDir(), "project")
+	files := map[string]string{
+		"readme.md":          "The ancient oak tree stood as a silent sentinel at the edge of the meadow.",
+		"src/main.go":        "package main\n\nfunc main() {}\n",
+		"src/empty.txt":      "",
+		"src/nested/data.md": "bobs burgers and fried",
+	}
+
+	for name, content := range files {
+		target := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %+v", err)
+		}
+
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %+v", err)
+		}
+	}
+
+	if err := os.Chmod(filepath.Join(root, "src/main.go"), 0o755); err != nil {
+		t.Fatalf("Chmod: %+v", err)
+	}
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "readme.md"), modTime, modTime); err != nil {
+		t.Fatalf("Chtimes: %+v", err)
+	}
+
+	if err := os.Symlink("nested/data.md", filepath.Join(root, "src/link.md")); err != nil {
+		t.Fatalf("Symlink: %+v", err)
+	}
+
+	return root
+}
+
+func TestCanArchiveAndExtractDirectory(t *testing.T) {
+	root := helperWriteTree(t)
+
+	compressed, err := CompressArchive([]string{root}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	entries, err := ListArchive(compressed)
+	if err != nil {
+		t.Fatalf("ListArchive: %+v", err)
+	}
+
+	// root dir, src, nested + 4 files + the symlink
+	if len(entries) != 8 {
+		t.Fatalf("expected 8 entries, got %d", len(entries))
+	}
+
+	dest := t.TempDir()
+	if _, err := ExtractArchive(compressed, dest, nil, false, DecodeOptions{}); err != nil {
+		t.Fatalf("ExtractArchive: %+v", err)
+	}
+
+	err = filepath.WalkDir(root, func(walked string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(filepath.Dir(root), walked)
+		extracted := filepath.Join(dest, rel)
+
+		wantInfo, _ := os.Lstat(walked)
+		gotInfo, err := os.Lstat(extracted)
+		if err != nil {
+			return fmt.Errorf("%s was not extracted: %w", rel, err)
+		}
+
+		if gotInfo.Mode() != wantInfo.Mode() {
+			return fmt.Errorf("%s mode %s, wanted %s", rel, gotInfo.Mode(), wantInfo.Mode())
+		}
+
+		switch {
+		case wantInfo.Mode()&fs.ModeSymlink != 0:
+			wantTarget, _ := os.Readlink(walked)
+			gotTarget, _ := os.Readlink(extracted)
+			if gotTarget != wantTarget {
+				return fmt.Errorf("%s links to %s, wanted %s", rel, gotTarget, wantTarget)
+			}
+		case wantInfo.Mode().IsRegular():
+			want, _ := os.ReadFile(walked)
+			got, _ := os.ReadFile(extracted)
+			if string(got) != string(want) {
+				return fmt.Errorf("%s content %q, wanted %q", rel, got, want)
+			}
+
+			if !gotInfo.ModTime().Equal(wantInfo.ModTime()) {
+				return fmt.Errorf("%s modified at %s, wanted %s", rel, gotInfo.ModTime(), wantInfo.ModTime())
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+}
+
+func TestCanExtractSingleMember(t *testing.T) {
+	root := helperWriteTree(t)
+
+	compressed, err := CompressArchive([]string{root}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	dest := t.TempDir()
+	extracted, err := ExtractArchive(compressed, dest, []string{"project/src/nested"}, false, DecodeOptions{})
+	if err != nil {
+		t.Fatalf("ExtractArchive: %+v", err)
+	}
+
+	if len(extracted) != 2 {
+		t.Fatalf("expected the nested dir and its file, got %d entries", len(extracted))
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "project/src/nested/data.md"))
+	if err != nil || string(content) != "bobs burgers and fried" {
+		t.Fatalf("unexpected member content %q: %+v", content, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "project/readme.md")); err == nil {
+		t.Fatalf("readme.md should not have been extracted")
+	}
+
+	if _, err := ExtractArchive(compressed, dest, []string{"project/missing.txt"}, false, DecodeOptions{}); err == nil {
+		t.Fatalf("expected error for a member that is not in the archive")
+	}
+}
+
+func TestExtractRefusesToOverwriteWithoutForce(t *testing.T) {
+	root := helperWriteTree(t)
+
+	compressed, err := CompressArchive([]string{root}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	dest := t.TempDir()
+	if _, err := ExtractArchive(compressed, dest, nil, false, DecodeOptions{}); err != nil {
+		t.Fatalf("ExtractArchive: %+v", err)
+	}
+
+	// a hard link to a file outside dest, writing through it in place would change that file as well
+	shared := filepath.Join(t.TempDir(), "shared.md")
+	if err := os.WriteFile(shared, []byte("changed"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	readme := filepath.Join(dest, "project/readme.md")
+	os.Remove(readme)
+	if err := os.Link(shared, readme); err != nil {
+		t.Fatalf("Link: %+v", err)
+	}
+
+	if _, err := ExtractArchive(compressed, dest, []string{"project/readme.md"}, false, DecodeOptions{}); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected an exists error extracting over a file, got %+v", err)
+	}
+
+	if content, _ := os.ReadFile(readme); string(content) != "changed" {
+		t.Fatalf("existing file was overwritten without force: %q", content)
+	}
+
+	if _, err := ExtractArchive(compressed, dest, nil, true, DecodeOptions{}); err != nil {
+		t.Fatalf("ExtractArchive with force: %+v", err)
+	}
+
+	if content, _ := os.ReadFile(readme); string(content) == "changed" {
+		t.Fatalf("existing file was not overwritten with force")
+	}
+
+	if content, _ := os.ReadFile(shared); string(content) != "changed" {
+		t.Fatalf("overwriting with force wrote through a hard link: %q", content)
+	}
+}
+
+func TestExtractRejectsUnsafePaths(t *testing.T) {
+	cases := map[string][]*ArchiveEntry{
+		"parent dir": {
+			{Path: "../evil.txt", Size: 4, Mode: 0o644},
+		},
+		"absolute": {
+			{Path: "/tmp/evil.txt", Size: 4, Mode: 0o644},
+		},
+		"through symlink": {
+			{Path: "link", Mode: uint32(fs.ModeSymlink | 0o777), LinkTarget: os.TempDir()},
+			{Path: "link/evil.txt", Size: 4, Mode: 0o644},
+		},
+	}
+
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := compressWithMetadata(context.Background(), []byte("evil"), Options{}, &proto_data.CompressedFileMetaData{
+				Entries: entries,
+			})
+			if err != nil {
+				t.Fatalf("compressWithMetadata: %+v", err)
+			}
+
+			dest := t.TempDir()
+			if _, err := ExtractArchive(compressed, dest, nil, false, DecodeOptions{}); err == nil {
+				t.Fatalf("expected error for unsafe entries %+v", entries)
+			}
+
+			if _, err := os.Stat(filepath.Join(os.TempDir(), "evil.txt")); err == nil {
+				t.Fatalf("entry was written outside of the destination")
+			}
+		})
+	}
+}
+
+func TestExtractStaysWithinDecodeLimits(t *testing.T) {
+	root := helperWriteTree(t)
+
+	compressed, err := CompressArchive([]string{root}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	dest := t.TempDir()
+	if _, err := ExtractArchive(compressed, dest, nil, false, DecodeOptions{MaxOutputSize: 10}); !errors.Is(err, sCError.ErrLimitExceeded) {
+		t.Fatalf("expected a limit error, got %+v", err)
+	}
+
+	if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+		t.Fatalf("expected nothing to be extracted over the limit, got %d entries", len(entries))
+	}
+}
+
+func TestListRejectsPlainCompressedFile(t *testing.T) {
+	compressed, err := Compress([]byte("bananas"), Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if _, err := ListArchive(compressed); err == nil {
+		t.Fatalf("expected error listing a file that is not an archive")
+	}
+}
+
+func TestExtractDoesNotWriteThroughExtractedSymlink(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	entries := []*ArchiveEntry{
+		{Path: "out", Mode: uint32(fs.ModeSymlink | 0o777), LinkTarget: outside},
+		{Path: "out", Size: 4, Mode: 0o644},
+	}
+
+	compressed, err := compressWithMetadata(context.Background(), []byte("evil"), Options{}, &proto_data.CompressedFileMetaData{
+		Entries: entries,
+	})
+	if err != nil {
+		t.Fatalf("compressWithMetadata: %+v", err)
+	}
+
+	if _, err := ExtractArchive(compressed, t.TempDir(), nil, false, DecodeOptions{}); !errors.Is(err, sCError.ErrCorrupt) {
+		t.Fatalf("expected a corrupt error for an entry in the archive twice, got %+v", err)
+	}
+
+	if _, err := os.Lstat(outside); err == nil {
+		t.Fatalf("entry was written outside of the destination")
+	}
+
+	// a symlink already in the destination is not followed either
+	dest := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dest, "out")); err != nil {
+		t.Fatalf("Symlink: %+v", err)
+	}
+
+	compressed, err = compressWithMetadata(context.Background(), []byte("evil"), Options{}, &proto_data.CompressedFileMetaData{
+		Entries: entries[1:],
+	})
+	if err != nil {
+		t.Fatalf("compressWithMetadata: %+v", err)
+	}
+
+	if _, err := ExtractArchive(compressed, dest, nil, false, DecodeOptions{}); err == nil {
+		t.Fatalf("expected error writing an entry through a symlink")
+	}
+
+	// force replaces the symlink itself, not what it points to
+	if _, err := ExtractArchive(compressed, dest, nil, true, DecodeOptions{}); err != nil {
+		t.Fatalf("ExtractArchive with force: %+v", err)
+	}
+
+	if info, err := os.Lstat(filepath.Join(dest, "out")); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("expected the symlink to be replaced by a regular file: %+v", err)
+	}
+
+	if _, err := os.Lstat(outside); err == nil {
+		t.Fatalf("entry was written outside of the destination")
+	}
+}
@@ -0,0 +1,384 @@
+package stinkycompressor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	sCError "stinky-compression/error"
+	"strings"
+	"time"
+)
+
+// symlinks pointing at symlinks are followed at most this many times, like the linux kernel does
+const MAX_SYMLINK_HOPS = 40
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// ArchiveFS serves the entries of an archive as an fs.FS, file content is decoded lazily
+// so reading a file only decodes the blocks it is stored in.
+// Symlinks are followed as long as they stay inside of the archive
+type ArchiveFS struct {
+	reader  *Reader
+	entries map[string]*ArchiveEntry
+	// directories that are not in the archive themselves but have entries in them
+	implicit map[string]bool
+	// names of the direct children of every directory, "." being the root
+	children map[string][]string
+}
+
+var (
+	_ fs.ReadDirFS  = (*ArchiveFS)(nil)
+	_ fs.StatFS     = (*ArchiveFS)(nil)
+	_ fs.ReadFileFS = (*ArchiveFS)(nil)
+)
+
+// NewArchiveFS reads the entries of the archive in src, size is the length of src
+func NewArchiveFS(src io.ReaderAt, size int64) (*ArchiveFS, error) {
+	return NewArchiveFSWithOptions(src, size, DecodeOptions{})
+}
+
+// NewArchiveFSWithOptions is NewArchiveFS decoding file content within the limits of opts, see NewReaderWithOptions
+func NewArchiveFSWithOptions(src io.ReaderAt, size int64, opts DecodeOptions) (*ArchiveFS, error) {
+	reader, err := NewReaderWithOptions(src, size, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsArchive(reader.Metadata()) {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file is not an archive",
+		}
+	}
+
+	archive := &ArchiveFS{
+		reader:   reader,
+		entries:  map[string]*ArchiveEntry{".": implicitDir(".")},
+		implicit: map[string]bool{".": true},
+		children: map[string][]string{},
+	}
+
+	for _, entry := range reader.Metadata().GetEntries() {
+		if err := archive.add(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, names := range archive.children {
+		slices.Sort(names)
+	}
+
+	return archive, nil
+}
+
+func implicitDir(name string) *ArchiveEntry {
+	return &ArchiveEntry{Path: name, Mode: uint32(fs.ModeDir | 0o555)}
+}
+
+func (a *ArchiveFS) add(entry *ArchiveEntry) error {
+	name := entry.GetPath()
+	if !fs.ValidPath(name) || name == "." {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("archive entry %q is not a valid path", name),
+		}
+	}
+
+	if err := validateEntry(entry, a.reader.Size()); err != nil {
+		return err
+	}
+
+	if _, ok := a.entries[name]; ok {
+		// a directory that was only implied by entries before it gets its real entry
+		if !a.implicit[name] || !fs.FileMode(entry.GetMode()).IsDir() {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("archive entry %q is in the archive twice", name),
+			}
+		}
+
+		a.entries[name] = entry
+		delete(a.implicit, name)
+		return nil
+	}
+
+	a.entries[name] = entry
+
+	for child, parent := name, path.Dir(name); ; child, parent = parent, path.Dir(parent) {
+		a.children[parent] = append(a.children[parent], path.Base(child))
+
+		if existing, ok := a.entries[parent]; ok {
+			if !fs.FileMode(existing.GetMode()).IsDir() {
+				return &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.CODE_CORRUPT,
+					Message:  fmt.Sprintf("archive entry %q is inside %q which is not a directory", name, parent),
+				}
+			}
+
+			return nil
+		}
+
+		a.entries[parent] = implicitDir(parent)
+		a.implicit[parent] = true
+	}
+}
+
+// walks name and returns the path behind the first symlink on the way, or the entry once there are none left
+func (a *ArchiveFS) step(name string, followLast bool) (*ArchiveEntry, string, error) {
+	if name == "." {
+		return a.entries["."], "", nil
+	}
+
+	parts := strings.Split(name, "/")
+	for idx := range parts {
+		walked := strings.Join(parts[:idx+1], "/")
+		entry, ok := a.entries[walked]
+		if !ok {
+			return nil, "", fs.ErrNotExist
+		}
+
+		mode := fs.FileMode(entry.GetMode())
+		last := idx == len(parts)-1
+
+		if mode&fs.ModeSymlink != 0 && (!last || followLast) {
+			target := path.Join(path.Dir(walked), entry.GetLinkTarget(), strings.Join(parts[idx+1:], "/"))
+			// links leading outside of the archive point at nothing we can serve
+			if path.IsAbs(entry.GetLinkTarget()) || !fs.ValidPath(target) {
+				return nil, "", fs.ErrNotExist
+			}
+
+			return nil, target, nil
+		}
+
+		if last {
+			return entry, "", nil
+		}
+
+		if !mode.IsDir() {
+			return nil, "", fs.ErrNotExist
+		}
+	}
+
+	return nil, "", fs.ErrNotExist
+}
+
+func (a *ArchiveFS) resolve(op, name string, followLast bool) (*ArchiveEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	current := name
+	for range MAX_SYMLINK_HOPS {
+		entry, next, err := a.step(current, followLast)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+
+		if entry != nil {
+			return entry, nil
+		}
+
+		current = next
+	}
+
+	return nil, &fs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+}
+
+func (a *ArchiveFS) Open(name string) (fs.File, error) {
+	entry, err := a.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info := archiveFileInfo{name: path.Base(name), entry: entry}
+	if info.IsDir() {
+		return &archiveDir{archive: a, info: info, children: a.children[entry.GetPath()]}, nil
+	}
+
+	return &archiveFile{
+		info:    info,
+		content: io.NewSectionReader(a.reader, entry.GetOffset(), entry.GetSize()),
+	}, nil
+}
+
+func (a *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := a.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiveFileInfo{name: path.Base(name), entry: entry}, nil
+}
+
+// Lstat is Stat without following a symlink at name itself
+func (a *ArchiveFS) Lstat(name string) (fs.FileInfo, error) {
+	entry, err := a.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiveFileInfo{name: path.Base(name), entry: entry}, nil
+}
+
+// ReadLink returns where the symlink at name points to
+func (a *ArchiveFS) ReadLink(name string) (string, error) {
+	entry, err := a.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+
+	if fs.FileMode(entry.GetMode())&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return entry.GetLinkTarget(), nil
+}
+
+// ReadDir lists the entries of a directory sorted by name, symlinks in it are not followed
+func (a *ArchiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := a.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fs.FileMode(entry.GetMode()).IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return a.dirEntries(entry.GetPath(), a.children[entry.GetPath()]), nil
+}
+
+func (a *ArchiveFS) dirEntries(dir string, names []string) []fs.DirEntry {
+	dirEntries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		entry := a.entries[path.Join(dir, name)]
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(archiveFileInfo{name: name, entry: entry}))
+	}
+
+	return dirEntries
+}
+
+func (a *ArchiveFS) ReadFile(name string) ([]byte, error) {
+	entry, err := a.resolve("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.FileMode(entry.GetMode()).IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	content := make([]byte, entry.GetSize())
+	if _, err := a.reader.ReadAt(content, entry.GetOffset()); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return content, nil
+}
+
+type archiveFileInfo struct {
+	// the name it was opened with, which differs from the entry behind a symlink
+	name  string
+	entry *ArchiveEntry
+}
+
+func (i archiveFileInfo) Name() string       { return i.name }
+func (i archiveFileInfo) Size() int64        { return i.entry.GetSize() }
+func (i archiveFileInfo) Mode() fs.FileMode  { return fs.FileMode(i.entry.GetMode()) }
+func (i archiveFileInfo) ModTime() time.Time { return time.Unix(0, i.entry.GetModTime()) }
+func (i archiveFileInfo) IsDir() bool        { return i.Mode().IsDir() }
+func (i archiveFileInfo) Sys() any           { return i.entry }
+
+type archiveFile struct {
+	info    archiveFileInfo
+	content *io.SectionReader
+	closed  bool
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *archiveFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.info.name, Err: fs.ErrClosed}
+	}
+
+	return f.content.Read(p)
+}
+
+func (f *archiveFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.info.name, Err: fs.ErrClosed}
+	}
+
+	return f.content.ReadAt(p, off)
+}
+
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: fs.ErrClosed}
+	}
+
+	return f.content.Seek(offset, whence)
+}
+
+func (f *archiveFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.info.name, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+	return nil
+}
+
+type archiveDir struct {
+	archive  *ArchiveFS
+	info     archiveFileInfo
+	children []string
+	// how many children ReadDir has returned so far
+	read   int
+	closed bool
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.info.name, Err: fs.ErrClosed}
+	}
+
+	remaining := d.children[d.read:]
+	if count > 0 {
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		}
+
+		remaining = remaining[:min(count, len(remaining))]
+	}
+
+	d.read += len(remaining)
+	return d.archive.dirEntries(d.info.entry.GetPath(), remaining), nil
+}
+
+func (d *archiveDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.info.name, Err: fs.ErrClosed}
+	}
+
+	d.closed = true
+	return nil
+}
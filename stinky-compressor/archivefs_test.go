@@ -0,0 +1,112 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"testing"
+	"testing/fstest"
+)
+
+func helperArchiveFS(t *testing.T) *ArchiveFS {
+	compressed, err := CompressArchive([]string{helperWriteTree(t)}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	archive, err := NewArchiveFS(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewArchiveFS: %+v", err)
+	}
+
+	return archive
+}
+
+func TestArchiveFSPassesFSTest(t *testing.T) {
+	archive := helperArchiveFS(t)
+
+	err := fstest.TestFS(archive, "project/readme.md", "project/src/main.go", "project/src/empty.txt", "project/src/nested/data.md")
+	if err != nil {
+		t.Fatalf("TestFS: %+v", err)
+	}
+}
+
+func TestArchiveFSReadsFilesAndLinks(t *testing.T) {
+	archive := helperArchiveFS(t)
+
+	content, err := fs.ReadFile(archive, "project/src/link.md")
+	if err != nil || string(content) != "bobs burgers and fried" {
+		t.Fatalf("reading through the symlink got %q: %+v", content, err)
+	}
+
+	sub, err := fs.Sub(archive, "project/src")
+	if err != nil {
+		t.Fatalf("Sub: %+v", err)
+	}
+
+	content, err = fs.ReadFile(sub, "main.go")
+	if err != nil || string(content) != "package main\n\nfunc main() {}\n" {
+		t.Fatalf("reading through Sub got %q: %+v", content, err)
+	}
+
+	info, err := archive.Lstat("project/src/link.md")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("expected Lstat to not follow the symlink, got %+v: %+v", info, err)
+	}
+
+	if _, err := archive.Open("project/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %+v", err)
+	}
+
+	if _, err := archive.Open("../project"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected fs.ErrInvalid, got %+v", err)
+	}
+}
+
+func TestArchiveFSDoesNotFollowLinksOutside(t *testing.T) {
+	compressed, err := compressWithMetadata(context.Background(), []byte{}, Options{}, &proto_data.CompressedFileMetaData{
+		Entries: []*ArchiveEntry{
+			{Path: "dir/up", Mode: uint32(fs.ModeSymlink | 0o777), LinkTarget: "../.."},
+			{Path: "dir/abs", Mode: uint32(fs.ModeSymlink | 0o777), LinkTarget: "/etc"},
+			{Path: "dir/loop", Mode: uint32(fs.ModeSymlink | 0o777), LinkTarget: "loop"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compressWithMetadata: %+v", err)
+	}
+
+	archive, err := NewArchiveFS(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewArchiveFS: %+v", err)
+	}
+
+	for _, name := range []string{"dir/up/x", "dir/abs/passwd", "dir/loop"} {
+		if _, err := archive.Open(name); err == nil {
+			t.Fatalf("expected %s to not be opened", name)
+		}
+	}
+
+	// dir is only implied by the links in it
+	if entries, err := archive.ReadDir("dir"); err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 entries in dir, got %d: %+v", len(entries), err)
+	}
+}
+
+func TestArchiveFSWithOptionsStaysWithinDecodeLimits(t *testing.T) {
+	compressed, err := CompressArchive([]string{helperWriteTree(t)}, Options{})
+	if err != nil {
+		t.Fatalf("CompressArchive: %+v", err)
+	}
+
+	archive, err := NewArchiveFSWithOptions(bytes.NewReader(compressed), int64(len(compressed)), DecodeOptions{MaxOutputSize: 8})
+	if err == nil {
+		_, err = fs.ReadFile(archive, "project/src/main.go")
+	}
+
+	if !errors.Is(err, sCError.ErrLimitExceeded) {
+		t.Fatalf("expected a limit error reading a file over the limit, got %+v", err)
+	}
+}
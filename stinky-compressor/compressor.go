@@ -2,12 +2,14 @@ package stinkycompressor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"math"
 	"os"
-	"path/filepath"
 	"stinky-compression/bwt"
 	sCError "stinky-compression/error"
-	sCFile "stinky-compression/file"
 	"stinky-compression/huffman"
 	"stinky-compression/mft"
 	proto_data "stinky-compression/proto/proto-data"
@@ -15,53 +17,144 @@ import (
 	"stinky-compression/rle"
 	"stinky-compression/writer"
 	"strconv"
-	"strings"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
 const (
 	COMPRESSED_FILE_EXTENSION = "stinkc"
+	// version 1 splits the input into checksummed blocks
+	CONTAINER_VERSION = 1
+	// block size of DEFAULT_LEVEL
+	BLOCK_SIZE = 128 * 1024
+	// decoding refuses blocks claiming to be larger than this
+	MAX_BLOCK_SIZE = 16 * 1024 * 1024
+	// version 0 files are a single block of the whole input, only the int32 bwt index bounds them
+	MAX_LEGACY_BLOCK_SIZE = math.MaxInt32 - 1
+	// longest meta size prefix accepted before the '#' separator
+	MAX_META_SIZE_DIGITS = 10
 )
 
 func deleteFile(filename string) error {
 	return os.Remove(filename)
 }
 
-func makeCompressedFileName(fromFileName string) string {
-	ext := filepath.Ext(fromFileName)
-	if ext == "" {
-		return fmt.Sprintf("%s.%s", fromFileName, COMPRESSED_FILE_EXTENSION)
+// CompressedFileName is where WriteCompressionToFile saves the compression of fromFileName
+func CompressedFileName(fromFileName string) string {
+	// the extension is kept so a.txt and a.md do not both end up as a.stinkc
+	return fmt.Sprintf("%s.%s", fromFileName, COMPRESSED_FILE_EXTENSION)
+}
+
+// bits of a coded block, rounded up to the bytes it takes
+func encodedBytes(encoded []huffman.CharPathEncoding) int {
+	bits := 0
+	for _, enc := range encoded {
+		bits += enc.Size
 	}
 
-	filenameSplit := strings.Split(fromFileName, ext)
-	if len(filenameSplit) == 0 {
-		return fmt.Sprintf("%s.%s", fromFileName, COMPRESSED_FILE_EXTENSION)
+	return (bits + 7) / 8
+}
+
+// stats is filled in when not nil, the counts that take a pass over the data are skipped otherwise
+func encodeBlock(ctx context.Context, input []byte, settings levelSettings, opts Options, tracker *progressTracker, stats *BlockStats) (*proto_data.CompressedFileMetaData_Block, []byte, error) {
+	collectStats := stats != nil
+	if !collectStats {
+		stats = &BlockStats{}
 	}
 
-	rawFileName := filenameSplit[0]
+	symbols, bwtIdx, rleDict := input, 0, []int32(nil)
+	if !settings.huffmanOnly {
+		tracker.report(STAGE_TRANSFORM)
+
+		// the steps of huffman.Transform one by one so every step can be timed
+		start := time.Now()
+		bwtCoded, primaryIdx := bwt.Bwt(input)
+		stats.BwtTime, start = time.Since(start), time.Now()
+		rleCoded, counts := rle.Rle(bwtCoded)
+		stats.RleTime, start = time.Since(start), time.Now()
+		symbols, bwtIdx, rleDict = mft.Mft(rleCoded), primaryIdx, counts
+		stats.MftTime = time.Since(start)
+
+		if collectStats {
+			stats.InputRuns = countRuns(input)
+			stats.BwtRuns = countRuns(bwtCoded)
+			stats.RleSize = len(rleCoded)
+			stats.RleDictLen = len(rleDict)
+			stats.RleRatio = ratio(int64(len(rleCoded)), int64(len(bwtCoded)))
+			stats.MftZeroFraction = zeroFraction(symbols)
+		}
 
-	return fmt.Sprintf("%s.%s", rawFileName, COMPRESSED_FILE_EXTENSION)
-}
+		if err := checkContext(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
 
-func WriteCompressionToFile(input []byte, filename string, removeOldFile, debug bool) (string, error) {
-	encoded, frequencyTable, bwtIdx, rleDict := huffman.HuffmanEncoding(input, debug)
+	tracker.report(STAGE_HUFFMAN)
+	huffmanStart := time.Now()
+
+	encoded, frequencyTable := huffman.HuffmanOnlyEncoding(symbols, opts.Debug)
+	frequencies := huffman.FrequencyTableToProto(frequencyTable)
+	dictionaryTable, staticTable := false, uint32(0)
+	tables, selectors := []*proto_data.CompressedFileMetaData_Block_Table(nil), []byte(nil)
+
+	// runs are described by their header alone, anything else takes whichever table codes it smallest,
+	// its own table has to pay for storing its frequencies while static and dictionary tables are free
+	if len(encoded) > 0 {
+		bestSize := encodedBytes(encoded) + proto.Size(&proto_data.CompressedFileMetaData_Block{Frequencies: frequencies})
+		var bestTable huffman.FrequencyTable
+		smaller := func(table huffman.FrequencyTable) bool {
+			bits, ok := huffman.CodedSize(frequencyTable, table)
+			if !ok || (bits+7)/8 >= bestSize {
+				return false
+			}
 
-	compressedFileName := makeCompressedFileName(filename)
+			bestSize, bestTable = (bits+7)/8, table
+			return true
+		}
 
-	if !sCFile.FileExists(compressedFileName) {
-		if err := sCFile.CreateFile(compressedFileName); err != nil {
-			return compressedFileName, err
+		if !opts.NoStaticTables {
+			for _, static := range huffman.StaticTables(!settings.huffmanOnly) {
+				if smaller(static.Table()) {
+					staticTable = static.ID
+				}
+			}
 		}
 
-	}
+		if opts.Dictionary != nil && smaller(opts.Dictionary.table(settings.huffmanOnly)) {
+			dictionaryTable, staticTable = true, 0
+		}
 
-	file, err := sCFile.OpenFileWithWritePermissions(compressedFileName)
-	if err != nil {
-		return compressedFileName, err
-	}
+		if bestTable != nil {
+			tableEncoded, err := huffman.EncodeWithTable(symbols, bestTable)
+			if err != nil {
+				return nil, nil, err
+			}
 
-	defer file.Close()
+			encoded, frequencies = tableEncoded, nil
+		}
+
+		// several tables pay for storing every one of them and a selector per group, they are only kept when that is still smaller
+		if settings.tables > 1 {
+			optimized, optimizedSelectors := huffman.OptimizeTables(symbols, settings.tables, settings.iterations)
+			if len(optimized) > 1 {
+				tablesEncoded, err := huffman.EncodeWithTables(symbols, optimized, optimizedSelectors)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				optimizedBlock := &proto_data.CompressedFileMetaData_Block{Frequencies: huffman.FrequencyTableToProto(optimized[0]), Selectors: optimizedSelectors}
+				for _, table := range optimized[1:] {
+					optimizedBlock.Tables = append(optimizedBlock.Tables, &proto_data.CompressedFileMetaData_Block_Table{Frequencies: huffman.FrequencyTableToProto(table)})
+				}
+
+				if encodedBytes(tablesEncoded)+proto.Size(optimizedBlock) < bestSize {
+					encoded, frequencies, tables, selectors = tablesEncoded, optimizedBlock.Frequencies, optimizedBlock.Tables, optimizedSelectors
+					dictionaryTable, staticTable = false, 0
+				}
+			}
+		}
+	}
 
 	buf := []byte{}
 	binBuf := bytes.NewBuffer(buf)
@@ -69,9 +162,11 @@ func WriteCompressionToFile(input []byte, filename string, removeOldFile, debug
 	for _, enc := range encoded {
 		err := binWriter.WriteBits(enc.Path, enc.Size)
 		if err != nil {
-			return compressedFileName, &sCError.CompressorError{
+			return nil, nil, &sCError.CompressorError{
 				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  fmt.Sprintf("failed to write bits: %+v", err),
+				Code:     sCError.CODE_INTERNAL,
+				Message:  "failed to write bits",
+				Err:      err,
 			}
 		}
 
@@ -79,62 +174,219 @@ func WriteCompressionToFile(input []byte, filename string, removeOldFile, debug
 
 	padding, err := binWriter.Flush()
 	if err != nil {
-		return compressedFileName, &sCError.CompressorError{
+		return nil, nil, &sCError.CompressorError{
 			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to flush bits: %+v", err),
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to flush bits",
+			Err:      err,
 		}
 	}
 
-	metadata := proto_data.CompressedFileMetaData{
-		EncodedLen:   int64(binBuf.Len()),
-		PaddingSize:  int32(padding),
-		OriginalSize: int64(len(input)),
-		Frequencies:  huffman.FrequencyTableToProto(frequencyTable),
-		BwtIdx:       int32(bwtIdx),
-		RleDict:      rleDict,
+	block := &proto_data.CompressedFileMetaData_Block{
+		EncodedLen:      int64(binBuf.Len()),
+		PaddingSize:     int32(padding),
+		OriginalSize:    int64(len(input)),
+		Frequencies:     frequencies,
+		BwtIdx:          int32(bwtIdx),
+		RleDict:         rleDict,
+		Checksum:        crc32.ChecksumIEEE(input),
+		HuffmanOnly:     settings.huffmanOnly,
+		DictionaryTable: dictionaryTable,
+		StaticTable:     staticTable,
+		Tables:          tables,
+		Selectors:       selectors,
 	}
 
-	metaBts, err := proto.Marshal(&metadata)
-	if err != nil {
-		return compressedFileName, &sCError.CompressorError{
-			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to marshal proto: %+v", err),
+	stats.HuffmanTime = time.Since(huffmanStart)
+	if collectStats {
+		stats.OriginalSize = int64(len(input))
+		stats.HuffmanOnly = settings.huffmanOnly
+		stats.Symbols = len(symbols)
+		stats.Entropy = entropy(frequencyTable)
+		stats.EncodedSize = block.EncodedLen
+		stats.Table = "own"
+		if dictionaryTable {
+			stats.Table = "dictionary"
+		}
+		if static, err := huffman.StaticTableByID(staticTable); err == nil {
+			stats.Table = "static " + static.Name
+		}
+		if len(tables) > 0 {
+			stats.Table = fmt.Sprintf("%d own", len(tables)+1)
+		}
+
+		if len(symbols) > 0 {
+			stats.BitsPerSymbol = float64(block.EncodedLen*8-int64(padding)) / float64(len(symbols))
 		}
 	}
 
-	metaBtsSize := len(metaBts)
+	return block, binBuf.Bytes(), nil
+}
 
-	_, err = file.Write([]byte(fmt.Sprintf("%d#", metaBtsSize)))
+// Compress encodes input block by block and returns the full compressed file content:
+// the metadata size followed by '#', the proto metadata and the encoded blocks one after another
+func Compress(input []byte, opts Options) ([]byte, error) {
+	return CompressContext(context.Background(), input, opts)
+}
+
+// CompressContext compresses like Compress and stops with an error matching sCError.ErrCanceled once ctx is done
+func CompressContext(ctx context.Context, input []byte, opts Options) ([]byte, error) {
+	return compressWithMetadata(ctx, input, opts, &proto_data.CompressedFileMetaData{})
+}
+
+// fills in the block and checksum fields of metadata, anything else set by the caller is kept as is
+func compressWithMetadata(ctx context.Context, input []byte, opts Options, metadata *proto_data.CompressedFileMetaData) ([]byte, error) {
+	settings, err := opts.settings()
 	if err != nil {
-		return compressedFileName, &sCError.CompressorError{
-			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to write meta bytes size: %+v", err),
+		return nil, err
+	}
+
+	compressStart := time.Now()
+	blocks := (len(input) + settings.blockSize - 1) / settings.blockSize
+	tracker := newProgressTracker(opts.Progress, blocks, int64(len(input)))
+	stats := opts.Stats
+	if stats == nil {
+		stats = &Stats{}
+	}
+	*stats = Stats{}
+
+	metadata.Version = CONTAINER_VERSION
+	metadata.OriginalSize = int64(len(input))
+	metadata.Checksum = crc32.ChecksumIEEE(input)
+
+	binBuf := bytes.NewBuffer([]byte{})
+	for start := 0; start < len(input); start += settings.blockSize {
+		end := min(start+settings.blockSize, len(input))
+
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		var blockStats *BlockStats
+		if opts.Stats != nil {
+			blockStats = &BlockStats{}
+		}
+
+		block, encoded, err := encodeBlock(ctx, input[start:end], settings, opts, tracker, blockStats)
+		if err != nil {
+			return nil, err
 		}
 
+		if blockStats != nil {
+			stats.Blocks = append(stats.Blocks, *blockStats)
+		}
+
+		if block.GetDictionaryTable() {
+			metadata.DictionaryId = opts.Dictionary.ID
+		}
+
+		metadata.Blocks = append(metadata.Blocks, block)
+		metadata.EncodedLen += block.EncodedLen
+		binBuf.Write(encoded)
+		tracker.blockDone(int64(end-start), int64(len(encoded)))
 	}
 
-	_, err = file.Write(metaBts)
+	metaBts, err := proto.Marshal(metadata)
 	if err != nil {
-		return compressedFileName, &sCError.CompressorError{
+		return nil, &sCError.CompressorError{
 			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to write meta bytes to file: %+v", err),
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal proto",
+			Err:      err,
+		}
+	}
+
+	content := bytes.NewBuffer([]byte{})
+	content.WriteString(fmt.Sprintf("%d#", len(metaBts)))
+	content.Write(metaBts)
+	dataStart := int64(content.Len())
+	binBuf.WriteTo(content)
+	if opts.Recovery > 0 {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		tracker.report(STAGE_RECOVERY)
+		recoveryStart, withoutRecovery := time.Now(), content.Len()
+		if err := writeRecovery(content, opts.Recovery); err != nil {
+			return nil, err
 		}
+		stats.RecoveryTime, stats.RecoverySize = time.Since(recoveryStart), int64(content.Len()-withoutRecovery)
+	}
+	writeBlockIndex(content, metadata.Blocks, dataStart)
+
+	compressed := content.Bytes()
+	if len(opts.Password) > 0 {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		tracker.report(STAGE_ENCRYPT)
+		encryptStart := time.Now()
+		compressed, err = Encrypt(compressed, opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		stats.EncryptTime = time.Since(encryptStart)
+	}
+
+	stats.OriginalSize = metadata.OriginalSize
+	stats.CompressedSize = int64(len(compressed))
+	stats.EncodedSize = metadata.EncodedLen
+	stats.MetadataSize = int64(len(metaBts))
+	stats.TotalTime = time.Since(compressStart)
+	stats.total()
+
+	tracker.report(STAGE_DONE)
+	return compressed, nil
+}
+
+// input does not have to come from an existing file, file info is only stored when it does
+func compressFromFile(ctx context.Context, input []byte, filename string, opts Options) ([]byte, error) {
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		return CompressContext(ctx, input, opts)
 	}
 
-	_, err = binBuf.WriteTo(file)
+	return CompressFileContext(ctx, input, info, opts)
+}
+
+// existing compressed files are only overwritten with force, removeOldFile removes filename
+// only once the compressed file is synced and decodes back to input
+func WriteCompressionToFile(input []byte, filename string, removeOldFile bool, force bool, opts Options) (string, error) {
+	return WriteCompressionToFileContext(context.Background(), input, filename, removeOldFile, force, opts)
+}
+
+// WriteCompressionToFileContext is WriteCompressionToFile stopping once ctx is done,
+// nothing is written when that happens or when the compressed file fails verification
+func WriteCompressionToFileContext(ctx context.Context, input []byte, filename string, removeOldFile bool, force bool, opts Options) (string, error) {
+	compressedFileName := CompressedFileName(filename)
+
+	content, err := compressFromFile(ctx, input, filename, opts)
 	if err != nil {
-		return compressedFileName, &sCError.CompressorError{
-			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to write bits to file: %+v", err),
+		return compressedFileName, err
+	}
+
+	// verified before it is moved into place so an output that does not decode back is never left behind
+	var check func(string) error
+	if removeOldFile {
+		check = func(tmpName string) error {
+			return verifyCompressedFile(ctx, tmpName, compressedFileName, input, opts)
 		}
 	}
 
+	if err := writeFileAtomic(compressedFileName, content, force, check); err != nil {
+		return compressedFileName, err
+	}
+
 	if removeOldFile {
 		err := deleteFile(filename)
 		if err != nil {
 			return compressedFileName, &sCError.CompressorError{
 				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_INFO,
-				Message:  fmt.Sprintf("Compression was succesfull but old file could not be removed: %s", err.Error()),
+				Code:     sCError.CODE_IO,
+				Message:  "Compression was succesfull but old file could not be removed",
+				Err:      err,
 			}
 		}
 	}
@@ -142,81 +394,284 @@ func WriteCompressionToFile(input []byte, filename string, removeOldFile, debug
 	return compressedFileName, nil
 }
 
-func DecodeCompressedFile(content []byte, debug bool) ([]byte, error) {
-	metaEndsIdx := 0
-	metaStartIdx := 0
-	metaSize := 0
-
-	accSizeStr := ""
-	for idx, bt := range content {
-		if bt != '#' {
-			accSizeStr += string(bt)
-		} else {
-			metaStartIdx = idx + 1
-			metaSizeAtoi, err := strconv.Atoi(accSizeStr)
-			if err != nil {
-				return nil, &sCError.CompressorError{
-					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-					Message:  fmt.Sprintf("failed to parse meta size: %+v", err),
-				}
-			}
+// reads "<metasize>#" from the start of a container, available is the whole container length
+func parseMetaSize(prefix []byte, available int) (int, int, error) {
+	sizeEndIdx := bytes.IndexByte(prefix, '#')
+	if sizeEndIdx < 0 || sizeEndIdx > MAX_META_SIZE_DIGITS {
+		return 0, 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "failed to find meta size, content is not a compressed file",
+		}
+	}
+
+	metaSize, err := strconv.Atoi(string(prefix[:sizeEndIdx]))
+	if err != nil {
+		return 0, 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "failed to parse meta size",
+			Err:      err,
+		}
+	}
 
-			metaSize = metaSizeAtoi
+	metaStartIdx := sizeEndIdx + 1
+	if metaSize < 0 || metaSize > available-metaStartIdx {
+		return 0, 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("meta size %d does not fit in %d bytes of content", metaSize, available-metaStartIdx),
+		}
+	}
+
+	return metaStartIdx, metaSize, nil
+}
 
-			break
+func unmarshalMetadata(metaBts []byte) (*proto_data.CompressedFileMetaData, error) {
+	metaR := &proto_data.CompressedFileMetaData{}
+	if err := proto.Unmarshal(metaBts, metaR); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "failed to unmarshal meta bytes",
+			Err:      err,
 		}
 	}
 
-	metaBtsRead := make([]byte, metaSize)
-	currMetaRead := 0
-	metaIdx := 0
-	for {
-		if currMetaRead == metaSize {
-			metaEndsIdx = metaStartIdx
-			break
+	if metaR.GetVersion() > CONTAINER_VERSION {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_UNSUPPORTED_VERSION,
+			Message:  fmt.Sprintf("container version %d is newer than the supported version %d", metaR.GetVersion(), CONTAINER_VERSION),
 		}
+	}
 
-		metaBtsRead[metaIdx] = content[metaStartIdx]
-		currMetaRead++
-		metaIdx++
-		metaStartIdx++
+	return metaR, nil
+}
+
+// splits compressed file content to its metadata and the encoded data following it
+func parseContainer(content []byte) (*proto_data.CompressedFileMetaData, []byte, error) {
+	metaStartIdx, metaSize, err := parseMetaSize(content, len(content))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	metaR := &proto_data.CompressedFileMetaData{}
-	err := proto.Unmarshal(metaBtsRead, metaR)
+	metaEndsIdx := metaStartIdx + metaSize
+
+	metaR, err := unmarshalMetadata(content[metaStartIdx:metaEndsIdx])
 	if err != nil {
+		return nil, nil, err
+	}
+
+	return metaR, content[metaEndsIdx:], nil
+}
+
+// ParseMetadata reads only the metadata of compressed file content without decoding any blocks
+func ParseMetadata(content []byte) (*proto_data.CompressedFileMetaData, error) {
+	metaR, _, err := parseContainer(content)
+	return metaR, err
+}
+
+// Blocks returns the blocks of metadata, version 0 files get their single block from the top level fields
+func Blocks(metaR *proto_data.CompressedFileMetaData) []*proto_data.CompressedFileMetaData_Block {
+	if metaR.GetVersion() < CONTAINER_VERSION {
+		return legacyBlocks(metaR)
+	}
+
+	return metaR.GetBlocks()
+}
+
+// version 0 files keep their only block in the top level metadata fields
+func legacyBlocks(metaR *proto_data.CompressedFileMetaData) []*proto_data.CompressedFileMetaData_Block {
+	return []*proto_data.CompressedFileMetaData_Block{
+		{
+			EncodedLen:   metaR.GetEncodedLen(),
+			PaddingSize:  metaR.GetPaddingSize(),
+			OriginalSize: metaR.GetOriginalSize(),
+			BwtIdx:       metaR.GetBwtIdx(),
+			Frequencies:  metaR.GetFrequencies(),
+			RleDict:      metaR.GetRleDict(),
+		},
+	}
+}
+
+// largest block a container of the given version can hold
+func maxBlockSize(metaR *proto_data.CompressedFileMetaData) int64 {
+	if metaR.GetVersion() < CONTAINER_VERSION {
+		return MAX_LEGACY_BLOCK_SIZE
+	}
+
+	return MAX_BLOCK_SIZE
+}
+
+// checks that block metadata is sane before any of it is used for allocations or indexing
+func validateBlock(block *proto_data.CompressedFileMetaData_Block, available int, maxSize int64) error {
+	if block.GetEncodedLen() < 0 || block.GetEncodedLen() > int64(available) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block encoded length %d does not fit in %d bytes of content", block.GetEncodedLen(), available),
+		}
+	}
+
+	if block.GetPaddingSize() < 0 || block.GetPaddingSize() > 7 {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("invalid block padding size %d", block.GetPaddingSize()),
+		}
+	}
+
+	if block.GetOriginalSize() < 0 || block.GetOriginalSize() > maxSize {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("invalid block original size %d", block.GetOriginalSize()),
+		}
+	}
+
+	if (block.GetDictionaryTable() || block.GetStaticTable() != 0) && len(block.GetFrequencies()) != 0 {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "block coded with a dictionary or static table has its own frequencies",
+		}
+	}
+
+	if block.GetDictionaryTable() && block.GetStaticTable() != 0 {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "block is coded with both a dictionary and a static table",
+		}
+	}
+
+	if len(block.GetTables()) > 0 && (block.GetDictionaryTable() || block.GetStaticTable() != 0) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "block coded with a dictionary or static table has more tables",
+		}
+	}
+
+	if len(block.GetTables())+1 > huffman.MAX_TABLES {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block has %d tables, at most %d are allowed", len(block.GetTables())+1, huffman.MAX_TABLES),
+		}
+	}
+
+	if len(block.GetTables()) == 0 && len(block.GetSelectors()) != 0 {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "block coded with a single table has selectors",
+		}
+	}
+
+	// every coded symbol takes at least a bit, only runs have none
+	symbols := int64(len(block.GetRleDict()))
+	if block.GetHuffmanOnly() {
+		symbols = block.GetOriginalSize()
+	}
+
+	if block.GetEncodedLen() > 0 && symbols > block.GetEncodedLen()*8 {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("%d symbols do not fit in %d encoded bytes", symbols, block.GetEncodedLen()),
+		}
+	}
+
+	if block.GetHuffmanOnly() {
+		if len(block.GetRleDict()) != 0 {
+			return &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  "huffman only block has an rle dict",
+			}
+		}
+
+		return nil
+	}
+
+	// the bwt output carries the primary index marker on top of the original content
+	expectedRleSize := int64(0)
+	if block.GetOriginalSize() > 0 {
+		expectedRleSize = block.GetOriginalSize() + 1
+	}
+
+	rleSize := int64(0)
+	for _, count := range block.GetRleDict() {
+		rleSize += int64(count)
+	}
+
+	if rleSize != expectedRleSize {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("rle dict expands to %d bytes, expected %d", rleSize, expectedRleSize),
+		}
+	}
+
+	return nil
+}
+
+// walks the huffman tree bit by bit until the encoded block data runs out, blocks with several tables
+// switch to the tree their selectors pick every huffman.TABLE_GROUP_SIZE symbols
+func decodeSymbols(encoded []byte, block *proto_data.CompressedFileMetaData_Block, tables []huffman.FrequencyTable, symbols int) ([]byte, error) {
+	binBuf := bytes.NewBuffer(encoded)
+	binReader := reader.NewBitReader(binBuf, block.GetEncodedLen(), int(block.GetPaddingSize()))
+
+	selectors := block.GetSelectors()
+	if len(tables) > 1 && len(selectors) != huffman.TableGroups(symbols) {
 		return nil, &sCError.CompressorError{
 			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-			Message:  fmt.Sprintf("failed to unmarshal meta bytes: %+v", err),
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block has %d selectors, expected %d", len(selectors), huffman.TableGroups(symbols)),
 		}
 	}
 
-	binData := make([]byte, metaR.EncodedLen)
-	binDataIdx := 0
-	bytesRead := int64(0)
-	for bytesRead < metaR.EncodedLen {
+	trees := make([]*huffman.Node, len(tables))
+	for idx, table := range tables {
+		if _, _, ok := table.Run(); ok && len(tables) > 1 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("table %d of the block has a single symbol", idx),
+			}
+		}
 
-		binData[binDataIdx] = content[metaEndsIdx]
-		binDataIdx++
-		metaEndsIdx++
-		bytesRead++
+		trees[idx] = huffman.TreeFromFrequencies(table)
 	}
 
-	binBuf := bytes.NewBuffer(binData)
-	binReader := reader.NewBitReader(binBuf, metaR.GetEncodedLen(), int(metaR.GetPaddingSize()))
+	for group, selector := range selectors {
+		if int(selector) >= len(trees) {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("selector %d picks table %d of %d", group, selector, len(trees)),
+			}
+		}
+	}
 
-	frequencyTable := huffman.ProtoFrequenciesToFrequencyTable(metaR.GetFrequencies())
-	tree := huffman.TreeFromFrequencies(frequencyTable)
+	tree := trees[0]
+	if len(selectors) > 0 {
+		tree = trees[selectors[0]]
+	}
 	head := tree
 
-	decoded := []byte{}
+	decoded := make([]byte, 0, symbols)
 
 	for binReader.Next() {
 		bit, err := binReader.ReadBit()
 		if err != nil {
 			return nil, &sCError.CompressorError{
 				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
-				Message:  fmt.Sprintf("read bit: %+v", err),
+				Code:     sCError.CODE_CORRUPT,
+				Message:  "read bit",
+				Err:      err,
 			}
 		}
 
@@ -224,21 +679,206 @@ func DecodeCompressedFile(content []byte, debug bool) ([]byte, error) {
 			continue
 		}
 
-		if bit == 1 {
-			head = head.Right
-		} else {
-			head = head.Left
+		head, err = head.Walk(bit)
+		if err != nil {
+			return nil, err
 		}
 
-		if head.Left == nil && head.Right == nil {
+		if head.IsLeaf() {
+			if len(decoded) == symbols {
+				return nil, &sCError.CompressorError{
+					Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+					Code:     sCError.CODE_CORRUPT,
+					Message:  fmt.Sprintf("block encodes more than the expected %d symbols", symbols),
+				}
+			}
+
 			decoded = append(decoded, head.Char)
+			if len(selectors) > 0 && len(decoded)%huffman.TABLE_GROUP_SIZE == 0 && len(decoded) < symbols {
+				tree = trees[selectors[len(decoded)/huffman.TABLE_GROUP_SIZE]]
+			}
 			head = tree
 		}
 	}
 
+	if len(decoded) != symbols {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block encodes %d symbols, expected %d", len(decoded), symbols),
+		}
+	}
+
+	return decoded, nil
+}
+
+// maxSize is the largest block the container version allows, see maxBlockSize
+func decodeBlock(block *proto_data.CompressedFileMetaData_Block, content []byte, maxSize int64, opts DecodeOptions) ([]byte, error) {
+	if err := validateBlock(block, len(content), maxSize); err != nil {
+		return nil, err
+	}
+
+	if block.GetOriginalSize() == 0 {
+		return []byte{}, nil
+	}
+
+	tables, err := BlockTables(block, opts.Dictionary)
+	if err != nil {
+		return nil, err
+	}
+	frequencyTable := tables[0]
+
+	symbols := len(block.GetRleDict())
+	if block.GetHuffmanOnly() {
+		symbols = int(block.GetOriginalSize())
+	}
+
+	var decoded []byte
+	if char, count, ok := frequencyTable.Run(); ok {
+		if count != symbols || block.GetEncodedLen() != 0 {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("run of %d symbols with %d encoded bytes does not match the expected %d symbols", count, block.GetEncodedLen(), symbols),
+			}
+		}
+
+		decoded = bytes.Repeat([]byte{char}, count)
+	} else {
+		decoded, err = decodeSymbols(content[:block.GetEncodedLen()], block, tables, symbols)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if block.GetHuffmanOnly() {
+		return decoded, nil
+	}
+
 	mftDecoded := mft.DecodeMft(decoded)
-	rleDecoded := rle.DecodeRle(mftDecoded, metaR.RleDict)
-	bwtDecoded := bwt.DecodeBwt(rleDecoded, int(metaR.BwtIdx))
+	rleDecoded, err := rle.DecodeRleWithLimit(mftDecoded, block.GetRleDict(), block.GetOriginalSize()+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return bwt.DecodeBwtWithLimit(rleDecoded, int(block.GetBwtIdx()), opts.MaxMemory)
+}
+
+func DecodeCompressedFile(content []byte, debug bool) ([]byte, error) {
+	return DecodeWithDictionary(content, nil)
+}
+
+// DecodeWithDictionary decodes content that was compressed with dict, dict can be nil for content compressed without one
+func DecodeWithDictionary(content []byte, dict *Dictionary) ([]byte, error) {
+	return DecodeWithOptions(content, DecodeOptions{Dictionary: dict})
+}
+
+// DecodeWithOptions decodes content within the limits of opts
+func DecodeWithOptions(content []byte, opts DecodeOptions) ([]byte, error) {
+	return DecodeContext(context.Background(), content, opts)
+}
+
+// DecodeContext decodes like DecodeWithOptions and stops with an error matching sCError.ErrCanceled once ctx is done
+func DecodeContext(ctx context.Context, content []byte, opts DecodeOptions) ([]byte, error) {
+	decoded, err := decodeContainer(ctx, content, opts)
+	if err == nil {
+		return decoded, nil
+	}
+
+	if errors.Is(err, sCError.ErrLimitExceeded) || errors.Is(err, sCError.ErrCanceled) {
+		return nil, err
+	}
+
+	// damage found by the block checksums, or that left the metadata unreadable, is repaired from the recovery record
+	repaired, rebuilt, ok, repairErr := repairContainer(content)
+	if repairErr != nil {
+		// the damage is what failed, the repair only explains why it stays, so err comes first and keeps its code
+		return nil, errors.Join(err, repairErr)
+	}
+
+	if !ok || rebuilt == 0 {
+		return nil, err
+	}
+
+	return decodeContainer(ctx, repaired, opts)
+}
+
+func decodeContainer(ctx context.Context, content []byte, opts DecodeOptions) ([]byte, error) {
+	metaR, binData, err := parseContainer(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotEncrypted(metaR); err != nil {
+		return nil, err
+	}
+
+	if err := checkDictionary(metaR, opts.Dictionary); err != nil {
+		return nil, err
+	}
+
+	return decodeBlocks(ctx, metaR, binData, opts)
+}
+
+func decodeBlocks(ctx context.Context, metaR *proto_data.CompressedFileMetaData, binData []byte, opts DecodeOptions) ([]byte, error) {
+	blocks := Blocks(metaR)
+	verifyChecksums := metaR.GetVersion() >= CONTAINER_VERSION
+
+	// every block is checked against the limits before the first one is decoded
+	decodedSize, encodedSize := int64(0), int64(0)
+	for _, block := range blocks {
+		if err := opts.checkBlock(block, decodedSize); err != nil {
+			return nil, err
+		}
+
+		decodedSize += max(block.GetOriginalSize(), 0)
+		encodedSize += max(block.GetEncodedLen(), 0)
+	}
+
+	tracker := newProgressTracker(opts.Progress, len(blocks), encodedSize)
+	decoded := []byte{}
+	for idx, block := range blocks {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		if err := opts.checkBlock(block, int64(len(decoded))); err != nil {
+			return nil, err
+		}
+
+		tracker.report(STAGE_DECODE)
+		blockDecoded, err := decodeBlock(block, binData, maxBlockSize(metaR), opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if verifyChecksums {
+			checksum := crc32.ChecksumIEEE(blockDecoded)
+			if checksum != block.GetChecksum() {
+				return nil, &sCError.ChecksumError{
+					Block:    idx,
+					Expected: block.GetChecksum(),
+					Actual:   checksum,
+				}
+			}
+		}
+
+		decoded = append(decoded, blockDecoded...)
+		binData = binData[block.GetEncodedLen():]
+		tracker.blockDone(block.GetEncodedLen(), int64(len(blockDecoded)))
+	}
+
+	if verifyChecksums {
+		checksum := crc32.ChecksumIEEE(decoded)
+		if checksum != metaR.GetChecksum() {
+			return nil, &sCError.ChecksumError{
+				Block:    sCError.CHECKSUM_WHOLE_FILE,
+				Expected: metaR.GetChecksum(),
+				Actual:   checksum,
+			}
+		}
+	}
 
-	return bwtDecoded, nil
+	tracker.report(STAGE_DONE)
+	return decoded, nil
 }
@@ -1,10 +1,22 @@
 package stinkycompressor
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"os"
+	"stinky-compression/bwt"
+	sCError "stinky-compression/error"
 	"stinky-compression/file"
+	"stinky-compression/huffman"
+	"stinky-compression/mft"
+	proto_data "stinky-compression/proto/proto-data"
+	"stinky-compression/rle"
+	"stinky-compression/writer"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
 )
 
 func helperDeleteFile(t *testing.T, filename string) {
@@ -23,7 +35,7 @@ func TestCanEncodeAndDecodeFromFile(t *testing.T) {
 			input := "The ancient oak tree stood as a silent sentinel at the edge of the meadow, its gnarled branches reaching skyward like arthritic fingers. Generation after generation had sought shelter beneath its broad canopy, from summer picnics to winter storms. Children had climbed its sturdy limbs, lovers had carved their initials into its weathered bark, and birds had built countless nests among its leaves. Through drought and flood, through war and peace, the tree remained a living testament to resilience and time. Locals claimed it was over three hundred years old, though no one knew for certain. What was known, however, was that the oak had become more than just a tree; it had become a landmark, a meeting place, a character in the story of the town itself. bobs burgers and fried."
 
 			asBytes := []byte(input)
-			compressedFileName, err := WriteCompressionToFile(asBytes, testFileName, false, false)
+			compressedFileName, err := WriteCompressionToFile(asBytes, testFileName, false, false, Options{})
 			if err != nil {
 				t.Fatalf("writeCompressionToFile: %+v", err)
 			}
@@ -44,3 +56,664 @@ func TestCanEncodeAndDecodeFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestCanEncodeAndDecodeMultipleBlocks(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	input := make([]byte, BLOCK_SIZE*2+BLOCK_SIZE/2)
+	for idx := range input {
+		input[idx] = byte('a' + rnd.IntN(8))
+	}
+
+	compressed, err := Compress(input, Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	metaR, _, err := parseContainer(compressed)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	if len(metaR.GetBlocks()) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(metaR.GetBlocks()))
+	}
+
+	decoded, err := DecodeCompressedFile(compressed, false)
+	if err != nil {
+		t.Fatalf("DecodeCompressedFile: %+v", err)
+	}
+
+	if !bytes.Equal(decoded, input) {
+		t.Fatalf("decoded content did not match input")
+	}
+}
+
+func TestDecodeReportsChecksumMismatch(t *testing.T) {
+	input := []byte("The ancient oak tree stood as a silent sentinel at the edge of the meadow.")
+
+	compressed, err := Compress(input, Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	metaR, binData, err := parseContainer(compressed)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	tamper := func(t *testing.T, wantBlock int) {
+		metaBts, err := proto.Marshal(metaR)
+		if err != nil {
+			t.Fatalf("proto.Marshal: %+v", err)
+		}
+
+		tampered := append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...)
+		tampered = append(tampered, binData...)
+
+		_, err = DecodeCompressedFile(tampered, false)
+		var checksumErr *sCError.ChecksumError
+		if !errors.As(err, &checksumErr) {
+			t.Fatalf("expected checksum error, got %+v", err)
+		}
+
+		if checksumErr.Block != wantBlock {
+			t.Fatalf("expected checksum error for block %d, got %d", wantBlock, checksumErr.Block)
+		}
+	}
+
+	t.Run("file", func(t *testing.T) {
+		metaR.Checksum++
+		defer func() { metaR.Checksum-- }()
+		tamper(t, sCError.CHECKSUM_WHOLE_FILE)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		metaR.Blocks[0].Checksum++
+		defer func() { metaR.Blocks[0].Checksum-- }()
+		tamper(t, 0)
+	})
+}
+
+func TestDecodeRejectsMalformedContent(t *testing.T) {
+	valid, err := Compress([]byte("my favourite food is bananas"), Options{NoStaticTables: true})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	metaR, binData, err := parseContainer(valid)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	withMeta := func(edit func(metaR *proto_data.CompressedFileMetaData)) []byte {
+		edited := proto.Clone(metaR).(*proto_data.CompressedFileMetaData)
+		edit(edited)
+
+		metaBts, err := proto.Marshal(edited)
+		if err != nil {
+			t.Fatalf("proto.Marshal: %+v", err)
+		}
+
+		content := append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...)
+		return append(content, binData...)
+	}
+
+	cases := map[string][]byte{
+		"empty":               {},
+		"no separator":        []byte("12345"),
+		"negative meta size":  []byte("-5#abc"),
+		"meta size too large": []byte("999#abc"),
+		"too many digits":     []byte("00000000000000000001#a"),
+		"truncated data":      valid[:len(valid)-INDEX_FOOTER_SIZE-INDEX_ENTRY_SIZE-3],
+		"garbage meta":        []byte("3#\xff\xff\xff"),
+		"empty char": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Frequencies[0].Char = []byte{}
+		}),
+		"no frequencies": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Frequencies = nil
+		}),
+		"single frequency": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Frequencies = m.Blocks[0].Frequencies[:1]
+		}),
+		"encoded len too large": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].EncodedLen = 1 << 40
+		}),
+		"bad padding": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].PaddingSize = 12
+		}),
+		"huge rle count": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].RleDict[0] = 1 << 30
+		}),
+		"missing rle counts": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].RleDict = m.Blocks[0].RleDict[:1]
+		}),
+		"bwt index out of range": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].BwtIdx = 1000
+		}),
+		"huge original size": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].OriginalSize = 1 << 40
+		}),
+		"unknown static table": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Frequencies = nil
+			m.Blocks[0].StaticTable = 99
+		}),
+		"static table with frequencies": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].StaticTable = huffman.STATIC_TABLE_BWT
+		}),
+		"dictionary table without dictionary": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Frequencies = nil
+			m.Blocks[0].DictionaryTable = true
+		}),
+		"selectors without tables": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			m.Blocks[0].Selectors = []byte{0}
+		}),
+		"too many tables": withMeta(func(m *proto_data.CompressedFileMetaData) {
+			for range huffman.MAX_TABLES {
+				m.Blocks[0].Tables = append(m.Blocks[0].Tables, &proto_data.CompressedFileMetaData_Block_Table{Frequencies: m.Blocks[0].Frequencies})
+			}
+		}),
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeCompressedFile(content, false)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+
+			if !errors.Is(err, sCError.ErrCorrupt) {
+				t.Fatalf("expected corrupt error, got %T: %+v", err, err)
+			}
+		})
+	}
+}
+
+func TestVersion1OnlyKeepsTotalsInLegacyFields(t *testing.T) {
+	input, compressed := helperSeekableInput(t)
+
+	metaR, binData, err := parseContainer(compressed)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	if metaR.GetPaddingSize() != 0 || metaR.GetBwtIdx() != 0 || len(metaR.GetFrequencies()) != 0 || len(metaR.GetRleDict()) != 0 {
+		t.Fatalf("expected the version 0 block fields to be empty")
+	}
+
+	originalSize, encodedLen := int64(0), int64(0)
+	for _, block := range metaR.GetBlocks() {
+		originalSize += block.GetOriginalSize()
+		encodedLen += block.GetEncodedLen()
+	}
+
+	if metaR.GetOriginalSize() != int64(len(input)) || metaR.GetOriginalSize() != originalSize || metaR.GetEncodedLen() != encodedLen {
+		t.Fatalf("expected totals %d and %d, got %d and %d", originalSize, encodedLen, metaR.GetOriginalSize(), metaR.GetEncodedLen())
+	}
+
+	withMeta := func(edit func(metaR *proto_data.CompressedFileMetaData)) []byte {
+		edited := proto.Clone(metaR).(*proto_data.CompressedFileMetaData)
+		edit(edited)
+
+		metaBts, err := proto.Marshal(edited)
+		if err != nil {
+			t.Fatalf("proto.Marshal: %+v", err)
+		}
+
+		content := append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...)
+		return append(content, binData...)
+	}
+
+	// the version 0 block fields are never read for newer versions
+	legacy := withMeta(func(m *proto_data.CompressedFileMetaData) {
+		m.PaddingSize, m.BwtIdx, m.RleDict = 3, 7, []int32{1, 2}
+		m.Frequencies = m.Blocks[0].Frequencies
+	})
+
+	if decoded, err := DecodeCompressedFile(legacy, false); err != nil || !bytes.Equal(decoded, input) {
+		t.Fatalf("expected the version 0 fields to be ignored: %+v", err)
+	}
+
+	// while the seekable reader checks its blocks against OriginalSize
+	wrongSize := withMeta(func(m *proto_data.CompressedFileMetaData) {
+		m.OriginalSize++
+	})
+
+	if _, err := NewReader(bytes.NewReader(wrongSize), int64(len(wrongSize))); !errors.Is(err, sCError.ErrCorrupt) {
+		t.Fatalf("expected corrupt error for a wrong original size, got %+v", err)
+	}
+}
+
+func TestDecodesVersion0File(t *testing.T) {
+	// written by the first release, a single block in the top level fields and no checksums
+	compressed, err := os.ReadFile("testdata/version0.stinkc")
+	if err != nil {
+		t.Fatalf("ReadFile: %+v", err)
+	}
+
+	want, err := os.ReadFile("testdata/version0.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %+v", err)
+	}
+
+	decoded, err := DecodeCompressedFile(compressed, false)
+	if err != nil || !bytes.Equal(decoded, want) {
+		t.Fatalf("decoded %q: %+v", decoded, err)
+	}
+
+	reader, err := NewReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %+v", err)
+	}
+
+	read := make([]byte, reader.Size())
+	if _, err := reader.ReadAt(read, 0); err != nil || !bytes.Equal(read, want) {
+		t.Fatalf("read %q: %+v", read, err)
+	}
+}
+
+// a version 0 file of a run of a, the bwt of a run is the run followed by the primary index marker
+func helperVersion0Run(t *testing.T, size int) []byte {
+	bwtCoded := append(bytes.Repeat([]byte{'a'}, size), bwt.PRIMARY_INDEX_MARKER)
+	rleCoded, rleDict := rle.Rle(bwtCoded)
+	encoded, table := huffman.HuffmanOnlyEncoding(mft.Mft(rleCoded), false)
+
+	binBuf := bytes.NewBuffer([]byte{})
+	binWriter := writer.NewBitWriter(binBuf)
+	for _, enc := range encoded {
+		if err := binWriter.WriteBits(enc.Path, enc.Size); err != nil {
+			t.Fatalf("WriteBits: %+v", err)
+		}
+	}
+
+	padding, err := binWriter.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %+v", err)
+	}
+
+	metaBts, err := proto.Marshal(&proto_data.CompressedFileMetaData{
+		EncodedLen:   int64(binBuf.Len()),
+		PaddingSize:  int32(padding),
+		OriginalSize: int64(size),
+		Frequencies:  huffman.FrequencyTableToProto(table),
+		RleDict:      rleDict,
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %+v", err)
+	}
+
+	return append(append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...), binBuf.Bytes()...)
+}
+
+func TestDecodesVersion0FileAboveMaxBlockSize(t *testing.T) {
+	size := MAX_BLOCK_SIZE + 1024
+	compressed := helperVersion0Run(t, size)
+
+	decoded, err := DecodeCompressedFile(compressed, false)
+	if err != nil || len(decoded) != size || bytes.Count(decoded, []byte{'a'}) != size {
+		t.Fatalf("expected %d bytes of a, got %d: %+v", size, len(decoded), err)
+	}
+
+	limitErr := &sCError.LimitError{}
+	if _, err := DecodeWithOptions(compressed, DecodeOptions{MaxBlockSize: MAX_BLOCK_SIZE}); !errors.As(err, &limitErr) || limitErr.Limit != sCError.LIMIT_BLOCK_SIZE {
+		t.Fatalf("expected block size limit error, got %+v", err)
+	}
+
+	// the same block in a newer container is over MAX_BLOCK_SIZE
+	metaR, binData, err := parseContainer(compressed)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	metaR.Version = CONTAINER_VERSION
+	metaR.Blocks = legacyBlocks(metaR)
+	metaBts, err := proto.Marshal(metaR)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %+v", err)
+	}
+
+	if _, err := DecodeCompressedFile(append(append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...), binData...), false); !errors.Is(err, sCError.ErrCorrupt) || errors.Is(err, sCError.ErrChecksum) {
+		t.Fatalf("expected corrupt error for a version %d block above MAX_BLOCK_SIZE, got %+v", CONTAINER_VERSION, err)
+	}
+}
+
+func TestDecodeRejectsNewerVersion(t *testing.T) {
+	metaBts, err := proto.Marshal(&proto_data.CompressedFileMetaData{Version: CONTAINER_VERSION + 1})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %+v", err)
+	}
+
+	_, err = DecodeCompressedFile(append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...), false)
+	if !errors.Is(err, sCError.ErrUnsupportedVersion) {
+		t.Fatalf("expected unsupported version error, got %+v", err)
+	}
+
+	if errors.Is(err, sCError.ErrCorrupt) {
+		t.Fatalf("a newer version should not be reported as corrupt")
+	}
+}
+
+func TestDecodeDoesNotPanicOnCorruptedBytes(t *testing.T) {
+	valid, err := Compress([]byte("The ancient oak tree stood as a silent sentinel at the edge of the meadow."), Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	for idx := range valid {
+		for _, mask := range []byte{0x01, 0x80, 0xff} {
+			corrupted := bytes.Clone(valid)
+			corrupted[idx] ^= mask
+
+			// only panics fail here, corrupted content may still decode when a flip hits an unused bit
+			DecodeCompressedFile(corrupted, false)
+		}
+	}
+}
+
+func FuzzDecodeCompressedFile(f *testing.F) {
+	for _, input := range []string{"", "a", "%%%", "my favourite food is bananas"} {
+		compressed, err := Compress([]byte(input), Options{})
+		if err != nil {
+			f.Fatalf("Compress: %+v", err)
+		}
+
+		f.Add(compressed)
+	}
+
+	f.Add([]byte("0#"))
+	f.Add([]byte("3#abc"))
+
+	// small limits so inputs claiming huge blocks are rejected instead of stalling the fuzzer on allocations
+	opts := DecodeOptions{MaxOutputSize: 1024 * 1024, MaxBlockSize: 64 * 1024, MaxMemory: 16 * 1024 * 1024}
+	f.Fuzz(func(t *testing.T, content []byte) {
+		DecodeWithOptions(content, opts)
+	})
+}
+
+func TestCanEncodeAndDecodeEmptyAndSingleSymbolInput(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":         {},
+		"marker":        []byte("%"),
+		"marker run":    bytes.Repeat([]byte("%"), 1000),
+		"single byte":   []byte("a"),
+		"repeated byte": bytes.Repeat([]byte("a"), 1000),
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := Compress(input, Options{})
+			if err != nil {
+				t.Fatalf("Compress: %+v", err)
+			}
+
+			decoded, err := DecodeCompressedFile(compressed, false)
+			if err != nil {
+				t.Fatalf("DecodeCompressedFile: %+v", err)
+			}
+
+			if !bytes.Equal(decoded, input) {
+				t.Fatalf("decoded content did not match input, got %q wanted %q", decoded, input)
+			}
+		})
+	}
+}
+
+func TestCanEncodeAndDecodeEveryLevel(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+	input := make([]byte, 300*1024)
+	for idx := range input {
+		input[idx] = byte('a' + rnd.IntN(4))
+	}
+
+	for level := MIN_LEVEL; level <= MAX_LEVEL; level++ {
+		t.Run(fmt.Sprintf("level-%d", level), func(t *testing.T) {
+			compressed, err := Compress(input, Options{Level: level})
+			if err != nil {
+				t.Fatalf("Compress: %+v", err)
+			}
+
+			metaR, _, err := parseContainer(compressed)
+			if err != nil {
+				t.Fatalf("parseContainer: %+v", err)
+			}
+
+			settings := levels[level]
+			wantBlocks := (len(input) + settings.blockSize - 1) / settings.blockSize
+			if len(metaR.GetBlocks()) != wantBlocks {
+				t.Fatalf("expected %d blocks, got %d", wantBlocks, len(metaR.GetBlocks()))
+			}
+
+			if metaR.GetBlocks()[0].GetHuffmanOnly() != settings.huffmanOnly {
+				t.Fatalf("expected huffman only block: %t", settings.huffmanOnly)
+			}
+
+			decoded, err := DecodeCompressedFile(compressed, false)
+			if err != nil {
+				t.Fatalf("DecodeCompressedFile: %+v", err)
+			}
+
+			if !bytes.Equal(decoded, input) {
+				t.Fatalf("decoded content did not match input")
+			}
+		})
+	}
+}
+
+func TestHighLevelsCodeWithSeveralTables(t *testing.T) {
+	// two halves of a single block without a byte in common, the bwt keeps their contexts apart
+	// and the mft output of the few symbol half wants a very different code than the other one
+	rnd := rand.New(rand.NewPCG(5, 6))
+	input := make([]byte, 400*1024)
+	for idx := range input {
+		if idx < len(input)/2 {
+			input[idx] = byte('a' + rnd.IntN(4))
+		} else {
+			input[idx] = byte(0x80 + rnd.IntN(128))
+		}
+	}
+
+	single, err := Compress(input, Options{Level: MAX_LEVEL, NoStaticTables: true})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	metaR, binData, err := parseContainer(single)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	block := metaR.GetBlocks()[0]
+	if len(block.GetTables()) == 0 || len(block.GetSelectors()) != huffman.TableGroups(len(block.GetRleDict())) {
+		t.Fatalf("expected several tables with a selector per group, got %d tables and %d selectors", len(block.GetTables())+1, len(block.GetSelectors()))
+	}
+
+	decoded, err := DecodeCompressedFile(single, false)
+	if err != nil || !bytes.Equal(decoded, input) {
+		t.Fatalf("decoded content did not match input: %+v", err)
+	}
+
+	withBlock := func(edit func(block *proto_data.CompressedFileMetaData_Block)) []byte {
+		edited := proto.Clone(metaR).(*proto_data.CompressedFileMetaData)
+		edit(edited.Blocks[0])
+
+		metaBts, err := proto.Marshal(edited)
+		if err != nil {
+			t.Fatalf("proto.Marshal: %+v", err)
+		}
+
+		content := append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...)
+		return append(content, binData...)
+	}
+
+	cases := map[string][]byte{
+		"missing selector": withBlock(func(block *proto_data.CompressedFileMetaData_Block) {
+			block.Selectors = block.Selectors[1:]
+		}),
+		"selector out of range": withBlock(func(block *proto_data.CompressedFileMetaData_Block) {
+			block.Selectors[0] = byte(len(block.Tables) + 1)
+		}),
+		"tables with a static table": withBlock(func(block *proto_data.CompressedFileMetaData_Block) {
+			block.Frequencies, block.StaticTable = nil, huffman.STATIC_TABLE_BWT
+		}),
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCompressedFile(content, false); !errors.Is(err, sCError.ErrCorrupt) {
+				t.Fatalf("expected corrupt error, got %+v", err)
+			}
+		})
+	}
+
+	// levels without several tables keep coding blocks with one
+	fast, err := Compress(input, Options{Level: 4})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	fastMeta, err := ParseMetadata(fast)
+	if err != nil {
+		t.Fatalf("ParseMetadata: %+v", err)
+	}
+
+	for idx, block := range fastMeta.GetBlocks() {
+		if len(block.GetTables()) != 0 || len(block.GetSelectors()) != 0 {
+			t.Fatalf("level 4 block %d is coded with several tables", idx)
+		}
+	}
+}
+
+func TestCompressRejectsInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, MAX_LEVEL + 1} {
+		_, err := Compress([]byte("bananas"), Options{Level: level})
+		if err == nil {
+			t.Fatalf("expected error for level %d", level)
+		}
+	}
+}
+
+func TestStaticTablesShrinkTinyPayloads(t *testing.T) {
+	inputs := map[string]string{
+		"text": "The ancient oak tree stood as a silent sentinel at the edge of the meadow.",
+		"json": `{"customer":"bob","order":["burger","fries"],"paid":true}`,
+		"go":   "func main() {\n\tfmt.Println(\"bobs burgers\")\n}\n",
+	}
+
+	for name, input := range inputs {
+		for _, level := range []int{1, 6} {
+			dynamic, err := Compress([]byte(input), Options{Level: level, NoStaticTables: true})
+			if err != nil {
+				t.Fatalf("Compress: %+v", err)
+			}
+
+			static, err := Compress([]byte(input), Options{Level: level})
+			if err != nil {
+				t.Fatalf("Compress: %+v", err)
+			}
+
+			metaR, err := ParseMetadata(static)
+			if err != nil {
+				t.Fatalf("ParseMetadata: %+v", err)
+			}
+
+			block := metaR.GetBlocks()[0]
+			if block.GetStaticTable() == 0 || len(block.GetFrequencies()) != 0 {
+				t.Fatalf("%s level %d: expected a static table without frequencies", name, level)
+			}
+
+			if len(static) >= len(dynamic) {
+				t.Fatalf("%s level %d: static table gave %d bytes, own table %d", name, level, len(static), len(dynamic))
+			}
+
+			decoded, err := DecodeCompressedFile(static, false)
+			if err != nil || string(decoded) != input {
+				t.Fatalf("%s level %d: decoded %q: %+v", name, level, decoded, err)
+			}
+		}
+	}
+}
+
+func TestDecodeEnforcesLimits(t *testing.T) {
+	// a single symbol run codes to no bytes at all, 1M of it is a tiny file
+	run, err := Compress(bytes.Repeat([]byte{'a'}, 1<<20), Options{Level: 1})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	// thousands of blocks claiming the largest size allowed, each a run of a single symbol
+	bomb := &proto_data.CompressedFileMetaData{Version: CONTAINER_VERSION}
+	for range 4096 {
+		bomb.Blocks = append(bomb.Blocks, &proto_data.CompressedFileMetaData_Block{
+			OriginalSize: MAX_BLOCK_SIZE,
+			HuffmanOnly:  true,
+			Frequencies:  []*proto_data.CompressedFileMetaData_Frequency{{Char: []byte{'a'}, Frequency: MAX_BLOCK_SIZE}},
+		})
+	}
+
+	metaBts, err := proto.Marshal(bomb)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %+v", err)
+	}
+	bombContent := append([]byte(fmt.Sprintf("%d#", len(metaBts))), metaBts...)
+
+	bwtInput := []byte{}
+	for idx := range 2000 {
+		bwtInput = fmt.Appendf(bwtInput, "%d bobs burgers ", idx)
+	}
+
+	transformed, err := Compress(bwtInput, Options{Level: 4})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	cases := map[string]struct {
+		content []byte
+		opts    DecodeOptions
+		limit   string
+	}{
+		"output size":   {content: run, opts: DecodeOptions{MaxOutputSize: 1<<20 - 1}, limit: sCError.LIMIT_OUTPUT_SIZE},
+		"block size":    {content: run, opts: DecodeOptions{MaxBlockSize: 64 * 1024}, limit: sCError.LIMIT_BLOCK_SIZE},
+		"memory":        {content: run, opts: DecodeOptions{MaxMemory: 512 * 1024}, limit: sCError.LIMIT_MEMORY},
+		"bwt memory":    {content: transformed, opts: DecodeOptions{MaxMemory: int64(len(bwtInput))}, limit: sCError.LIMIT_MEMORY},
+		"bomb":          {content: bombContent, opts: DecodeOptions{MaxOutputSize: 1 << 30}, limit: sCError.LIMIT_OUTPUT_SIZE},
+		"bomb by block": {content: bombContent, opts: DecodeOptions{MaxBlockSize: 1 << 20}, limit: sCError.LIMIT_BLOCK_SIZE},
+		"bomb defaults": {content: bombContent, opts: DecodeOptions{}.WithDefaultLimits(), limit: sCError.LIMIT_OUTPUT_SIZE},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeWithOptions(tc.content, tc.opts)
+
+			limitErr := &sCError.LimitError{}
+			if !errors.As(err, &limitErr) || !errors.Is(err, sCError.ErrLimitExceeded) {
+				t.Fatalf("expected LimitError, got %+v", err)
+			}
+
+			if limitErr.Limit != tc.limit {
+				t.Fatalf("expected %s limit, got %s", tc.limit, limitErr.Limit)
+			}
+		})
+	}
+
+	decoded, err := DecodeWithOptions(run, DecodeOptions{MaxOutputSize: 1 << 20, MaxBlockSize: 256 * 1024, MaxMemory: 2 << 20})
+	if err != nil || len(decoded) != 1<<20 {
+		t.Fatalf("expected content within the limits to decode, got %d bytes: %+v", len(decoded), err)
+	}
+}
+
+func TestWithDefaultLimits(t *testing.T) {
+	opts := DecodeOptions{MaxOutputSize: 100, MaxMemory: -1}.WithDefaultLimits()
+	if opts.MaxOutputSize != 100 || opts.MaxBlockSize != DEFAULT_MAX_BLOCK_SIZE || opts.MaxMemory != -1 {
+		t.Fatalf("expected only the zero limit to get its default, got %+v", opts)
+	}
+
+	compressed, err := Compress([]byte("bobs burgers"), Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	// a negative limit is not enforced
+	decoded, err := DecodeWithOptions(compressed, DecodeOptions{MaxOutputSize: -1, MaxBlockSize: -1, MaxMemory: -1})
+	if err != nil || string(decoded) != "bobs burgers" {
+		t.Fatalf("expected negative limits to be ignored, got %d bytes: %+v", len(decoded), err)
+	}
+}
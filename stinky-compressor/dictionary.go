@@ -0,0 +1,288 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	sCError "stinky-compression/error"
+	"stinky-compression/huffman"
+	proto_data "stinky-compression/proto/proto-data"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// dictionary files start with this so they are not mistaken for anything else
+	DICTIONARY_MAGIC = "STNKDICT"
+	// trained frequencies are scaled down to about this total so code lengths stay short
+	DICTIONARY_SCALE = 1 << 20
+)
+
+// Dictionary holds frequency tables trained from sample inputs, blocks coded with it
+// do not store their own table which is most of the header of small inputs
+type Dictionary struct {
+	ID          uint32
+	raw         huffman.FrequencyTable
+	transformed huffman.FrequencyTable
+}
+
+// every symbol gets at least 1 so inputs with bytes the samples never had can still be coded
+func scaleFrequencies(counts [256]int) huffman.FrequencyTable {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	table := huffman.FrequencyTable{}
+	for char, count := range counts {
+		scaled := 0
+		if total > 0 {
+			scaled = int(int64(count) * DICTIONARY_SCALE / int64(total))
+		}
+
+		table[byte(char)] = scaled + 1
+	}
+
+	return table
+}
+
+// TrainDictionary counts the symbols of samples as both the huffman only and the bwt levels code them.
+// Samples should look like the inputs the dictionary is meant for, e.g. a few hundred small JSON messages
+func TrainDictionary(samples [][]byte) (*Dictionary, error) {
+	raw, transformed := [256]int{}, [256]int{}
+	trained := 0
+
+	for _, sample := range samples {
+		for start := 0; start < len(sample); start += BLOCK_SIZE {
+			chunk := sample[start:min(start+BLOCK_SIZE, len(sample))]
+			for _, bt := range chunk {
+				raw[bt]++
+			}
+
+			symbols, _, _ := huffman.Transform(chunk)
+			for _, bt := range symbols {
+				transformed[bt]++
+			}
+
+			trained++
+		}
+	}
+
+	if trained == 0 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "no samples with content to train a dictionary from",
+		}
+	}
+
+	dict := &Dictionary{
+		raw:         scaleFrequencies(raw),
+		transformed: scaleFrequencies(transformed),
+	}
+
+	var err error
+	dict.ID, err = dict.checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+func (d *Dictionary) table(huffmanOnly bool) huffman.FrequencyTable {
+	if huffmanOnly {
+		return d.raw
+	}
+
+	return d.transformed
+}
+
+// symbols in order so the same dictionary always marshals to the same bytes
+func sortedFrequencies(table huffman.FrequencyTable) []*proto_data.CompressedFileMetaData_Frequency {
+	frequencies := []*proto_data.CompressedFileMetaData_Frequency{}
+	for char := range 256 {
+		if freq, ok := table[byte(char)]; ok {
+			frequencies = append(frequencies, &proto_data.CompressedFileMetaData_Frequency{
+				Char:      []byte{byte(char)},
+				Frequency: int32(freq),
+			})
+		}
+	}
+
+	return frequencies
+}
+
+func (d *Dictionary) toProto() *proto_data.Dictionary {
+	return &proto_data.Dictionary{
+		Id:          d.ID,
+		Raw:         sortedFrequencies(d.raw),
+		Transformed: sortedFrequencies(d.transformed),
+	}
+}
+
+func (d *Dictionary) checksum() (uint32, error) {
+	withoutID := d.toProto()
+	withoutID.Id = 0
+
+	dictBts, err := proto.MarshalOptions{Deterministic: true}.Marshal(withoutID)
+	if err != nil {
+		return 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal dictionary",
+			Err:      err,
+		}
+	}
+
+	// 0 is what files without a dictionary have
+	return max(crc32.ChecksumIEEE(dictBts), 1), nil
+}
+
+// Marshal gives the content of a dictionary file
+func (d *Dictionary) Marshal() ([]byte, error) {
+	dictBts, err := proto.MarshalOptions{Deterministic: true}.Marshal(d.toProto())
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal dictionary",
+			Err:      err,
+		}
+	}
+
+	return append([]byte(DICTIONARY_MAGIC), dictBts...), nil
+}
+
+func dictionaryTable(frequencies []*proto_data.CompressedFileMetaData_Frequency) (huffman.FrequencyTable, error) {
+	table, err := huffman.ProtoFrequenciesToFrequencyTable(frequencies)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(table) != 256 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("dictionary table has %d symbols, expected 256", len(table)),
+		}
+	}
+
+	return table, nil
+}
+
+// LoadDictionary reads a dictionary file written from Marshal
+func LoadDictionary(content []byte) (*Dictionary, error) {
+	if !bytes.HasPrefix(content, []byte(DICTIONARY_MAGIC)) {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "content is not a dictionary",
+		}
+	}
+
+	dictR := &proto_data.Dictionary{}
+	if err := proto.Unmarshal(content[len(DICTIONARY_MAGIC):], dictR); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "failed to unmarshal dictionary",
+			Err:      err,
+		}
+	}
+
+	raw, err := dictionaryTable(dictR.GetRaw())
+	if err != nil {
+		return nil, err
+	}
+
+	transformed, err := dictionaryTable(dictR.GetTransformed())
+	if err != nil {
+		return nil, err
+	}
+
+	dict := &Dictionary{ID: dictR.GetId(), raw: raw, transformed: transformed}
+
+	checksum, err := dict.checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum != dict.ID {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("dictionary content does not match its id %08x", dict.ID),
+		}
+	}
+
+	return dict, nil
+}
+
+// files compressed with a dictionary can only be decoded with the same one
+func checkDictionary(metaR *proto_data.CompressedFileMetaData, dict *Dictionary) error {
+	switch {
+	case metaR.GetDictionaryId() == 0:
+		return nil
+	case dict == nil:
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("compressed with dictionary %08x, it is needed to decode", metaR.GetDictionaryId()),
+		}
+	case dict.ID != metaR.GetDictionaryId():
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("compressed with dictionary %08x, got dictionary %08x", metaR.GetDictionaryId(), dict.ID),
+		}
+	}
+
+	return nil
+}
+
+// BlockFrequencies are the frequencies a block was coded with, its own, a static table or the ones of dict
+func BlockFrequencies(block *proto_data.CompressedFileMetaData_Block, dict *Dictionary) (huffman.FrequencyTable, error) {
+	if block.GetStaticTable() != 0 {
+		static, err := huffman.StaticTableByID(block.GetStaticTable())
+		if err != nil {
+			return nil, err
+		}
+
+		return static.Table(), nil
+	}
+
+	if !block.GetDictionaryTable() {
+		return huffman.ProtoFrequenciesToFrequencyTable(block.GetFrequencies())
+	}
+
+	if dict == nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "block is coded with a dictionary but none was given",
+		}
+	}
+
+	return dict.table(block.GetHuffmanOnly()), nil
+}
+
+// BlockTables are all the tables a block was coded with, the one BlockFrequencies gives followed by the rest of its own
+func BlockTables(block *proto_data.CompressedFileMetaData_Block, dict *Dictionary) ([]huffman.FrequencyTable, error) {
+	first, err := BlockFrequencies(block, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := []huffman.FrequencyTable{first}
+	for _, table := range block.GetTables() {
+		frequencies, err := huffman.ProtoFrequenciesToFrequencyTable(table.GetFrequencies())
+		if err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, frequencies)
+	}
+
+	return tables, nil
+}
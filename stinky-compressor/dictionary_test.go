@@ -0,0 +1,136 @@
+package stinkycompressor
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func helperJSONMessages(count int, seed uint64) [][]byte {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	names := []string{"bob", "linda", "tina", "gene", "louise"}
+	foods := []string{"burger", "fries", "onion rings", "milkshake"}
+
+	messages := [][]byte{}
+	for range count {
+		messages = append(messages, fmt.Appendf(nil, `{"id":%d,"customer":"%s","order":["%s","%s"],"paid":%t,"total":%d.%02d}`,
+			rnd.IntN(100000), names[rnd.IntN(len(names))], foods[rnd.IntN(len(foods))], foods[rnd.IntN(len(foods))],
+			rnd.IntN(2) == 0, rnd.IntN(40), rnd.IntN(100)))
+	}
+
+	return messages
+}
+
+func TestDictionaryShrinksSmallMessages(t *testing.T) {
+	dict, err := TrainDictionary(helperJSONMessages(200, 1))
+	if err != nil {
+		t.Fatalf("TrainDictionary: %+v", err)
+	}
+
+	for _, level := range []int{1, 6} {
+		for _, message := range helperJSONMessages(5, 2) {
+			plain, err := Compress(message, Options{Level: level})
+			if err != nil {
+				t.Fatalf("Compress: %+v", err)
+			}
+
+			withDict, err := Compress(message, Options{Level: level, Dictionary: dict})
+			if err != nil {
+				t.Fatalf("Compress with dictionary: %+v", err)
+			}
+
+			if len(withDict) >= len(plain) {
+				t.Fatalf("level %d: expected dictionary to shrink %d bytes, got %d", level, len(plain), len(withDict))
+			}
+
+			decoded, err := DecodeWithDictionary(withDict, dict)
+			if err != nil || string(decoded) != string(message) {
+				t.Fatalf("level %d: decoded %q: %+v", level, decoded, err)
+			}
+
+			if _, err := DecodeCompressedFile(withDict, false); err == nil {
+				t.Fatalf("level %d: expected error decoding without the dictionary", level)
+			}
+		}
+	}
+}
+
+func TestDictionaryIsOnlyUsedWhenSmaller(t *testing.T) {
+	dict, err := TrainDictionary(helperJSONMessages(50, 1))
+	if err != nil {
+		t.Fatalf("TrainDictionary: %+v", err)
+	}
+
+	// a run is only its header and binary junk is nothing like the samples
+	rnd := rand.New(rand.NewPCG(5, 6))
+	junk := make([]byte, 4096)
+	for idx := range junk {
+		junk[idx] = byte(rnd.IntN(4))
+	}
+
+	for _, input := range [][]byte{[]byte("aaaaaaaaaaaaaaaa"), junk} {
+		compressed, err := Compress(input, Options{Level: 1, Dictionary: dict})
+		if err != nil {
+			t.Fatalf("Compress: %+v", err)
+		}
+
+		metaR, err := ParseMetadata(compressed)
+		if err != nil {
+			t.Fatalf("ParseMetadata: %+v", err)
+		}
+
+		if metaR.GetDictionaryId() != 0 {
+			t.Fatalf("expected own tables for %d bytes, got dictionary %08x", len(input), metaR.GetDictionaryId())
+		}
+	}
+}
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	dict, err := TrainDictionary(helperJSONMessages(20, 1))
+	if err != nil {
+		t.Fatalf("TrainDictionary: %+v", err)
+	}
+
+	content, err := dict.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %+v", err)
+	}
+
+	loaded, err := LoadDictionary(content)
+	if err != nil {
+		t.Fatalf("LoadDictionary: %+v", err)
+	}
+
+	if loaded.ID != dict.ID {
+		t.Fatalf("expected id %08x, got %08x", dict.ID, loaded.ID)
+	}
+
+	message := helperJSONMessages(1, 3)[0]
+	compressed, err := Compress(message, Options{Dictionary: dict})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if decoded, err := DecodeWithDictionary(compressed, loaded); err != nil || string(decoded) != string(message) {
+		t.Fatalf("decoding with the loaded dictionary got %q: %+v", decoded, err)
+	}
+
+	other, err := TrainDictionary([][]byte{[]byte("something else entirely")})
+	if err != nil {
+		t.Fatalf("TrainDictionary: %+v", err)
+	}
+
+	if _, err := DecodeWithDictionary(compressed, other); err == nil {
+		t.Fatalf("expected error decoding with another dictionary")
+	}
+
+	corrupted := append([]byte{}, content...)
+	corrupted[len(corrupted)-1]++
+	if _, err := LoadDictionary(corrupted); err == nil {
+		t.Fatalf("expected error loading a corrupted dictionary")
+	}
+
+	if _, err := TrainDictionary([][]byte{{}}); err == nil {
+		t.Fatalf("expected error training without content")
+	}
+}
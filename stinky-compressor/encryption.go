@@ -0,0 +1,323 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+
+	"golang.org/x/crypto/argon2"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// argon2id parameters from the second recommendation of RFC 9106
+	ENCRYPTION_TIME       = 3
+	ENCRYPTION_MEMORY     = 64 * 1024
+	ENCRYPTION_THREADS    = 4
+	ENCRYPTION_CHUNK_SIZE = 64 * 1024
+
+	ENCRYPTION_SALT_SIZE         = 16
+	ENCRYPTION_NONCE_PREFIX_SIZE = 4
+	ENCRYPTION_KEY_SIZE          = 32
+	ENCRYPTION_KEY_CHECK_SIZE    = 16
+	// the GCM tag every sealed chunk carries
+	ENCRYPTION_OVERHEAD = 16
+
+	// limits for parameters read from files so a crafted header can not make key derivation run for long,
+	// a few times the defaults. DecryptWithOptions can limit the memory further
+	MAX_ENCRYPTION_TIME   = 8
+	MAX_ENCRYPTION_MEMORY = 256 * 1024
+)
+
+type Encryption = proto_data.CompressedFileMetaData_Encryption
+
+func IsEncrypted(metaR *proto_data.CompressedFileMetaData) bool {
+	return metaR.GetEncrypted() != nil
+}
+
+// the key seals the chunks, the check is stored so a wrong password is noticed before any chunk is opened
+func deriveKey(password []byte, params *Encryption) ([]byte, []byte) {
+	derived := argon2.IDKey(password, params.GetSalt(), params.GetTime(), params.GetMemory(), uint8(params.GetThreads()), ENCRYPTION_KEY_SIZE+ENCRYPTION_KEY_CHECK_SIZE)
+	return derived[:ENCRYPTION_KEY_SIZE], derived[ENCRYPTION_KEY_SIZE:]
+}
+
+func newChunkCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to create cipher",
+			Err:      err,
+		}
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to create cipher",
+			Err:      err,
+		}
+	}
+
+	return aead, nil
+}
+
+func chunkNonce(params *Encryption, idx int) []byte {
+	return binary.BigEndian.AppendUint64(bytes.Clone(params.GetNoncePrefix()), uint64(idx))
+}
+
+// the header every chunk authenticates, the parameters the key was derived with and the original size
+func encryptionHeader(originalSize int64, params *Encryption) ([]byte, error) {
+	params = proto.Clone(params).(*Encryption)
+	params.HeaderChecksum = 0
+
+	header, err := proto.MarshalOptions{Deterministic: true}.Marshal(&proto_data.CompressedFileMetaData{
+		Version:      CONTAINER_VERSION,
+		OriginalSize: originalSize,
+		Encrypted:    params,
+	})
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal proto",
+			Err:      err,
+		}
+	}
+
+	return header, nil
+}
+
+// the header, the chunk index and whether it is the last one are authenticated so the parameters can not be
+// changed and chunks can not be reordered or cut off
+func chunkAdditionalData(header []byte, idx int, last bool) []byte {
+	additionalData := binary.BigEndian.AppendUint64(bytes.Clone(header), uint64(idx))
+	if last {
+		return append(additionalData, 1)
+	}
+
+	return append(additionalData, 0)
+}
+
+func chunkCount(size int64, chunkSize int64) int64 {
+	// empty content is still a single empty chunk so it is authenticated as well
+	return max((size+chunkSize-1)/chunkSize, 1)
+}
+
+// Encrypt seals compressed content with a key derived from password, Decrypt gives it back
+func Encrypt(compressed []byte, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "password can not be empty",
+		}
+	}
+
+	params := &Encryption{
+		Salt:        make([]byte, ENCRYPTION_SALT_SIZE),
+		Time:        ENCRYPTION_TIME,
+		Memory:      ENCRYPTION_MEMORY,
+		Threads:     ENCRYPTION_THREADS,
+		NoncePrefix: make([]byte, ENCRYPTION_NONCE_PREFIX_SIZE),
+		ChunkSize:   ENCRYPTION_CHUNK_SIZE,
+	}
+
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to generate salt",
+			Err:      err,
+		}
+	}
+
+	if _, err := rand.Read(params.NoncePrefix); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to generate nonce",
+			Err:      err,
+		}
+	}
+
+	key, check := deriveKey(password, params)
+	params.KeyCheck = check
+
+	header, err := encryptionHeader(int64(len(compressed)), params)
+	if err != nil {
+		return nil, err
+	}
+	params.HeaderChecksum = crc32.ChecksumIEEE(header)
+
+	aead, err := newChunkCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	metaBts, err := proto.Marshal(&proto_data.CompressedFileMetaData{
+		Version:      CONTAINER_VERSION,
+		OriginalSize: int64(len(compressed)),
+		Encrypted:    params,
+	})
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal proto",
+			Err:      err,
+		}
+	}
+
+	content := bytes.NewBuffer([]byte{})
+	content.WriteString(fmt.Sprintf("%d#", len(metaBts)))
+	content.Write(metaBts)
+
+	chunks := int(chunkCount(int64(len(compressed)), ENCRYPTION_CHUNK_SIZE))
+	for idx := range chunks {
+		chunk := compressed[idx*ENCRYPTION_CHUNK_SIZE : min((idx+1)*ENCRYPTION_CHUNK_SIZE, len(compressed))]
+		content.Write(aead.Seal(nil, chunkNonce(params, idx), chunk, chunkAdditionalData(header, idx, idx == chunks-1)))
+	}
+
+	return content.Bytes(), nil
+}
+
+func validateEncryption(params *Encryption, originalSize int64, available int) error {
+	invalid := func(message string) error {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("invalid encryption parameters: %s", message),
+		}
+	}
+
+	switch {
+	case len(params.GetSalt()) < ENCRYPTION_SALT_SIZE:
+		return invalid("salt is too short")
+	case params.GetTime() < 1 || params.GetTime() > MAX_ENCRYPTION_TIME:
+		return invalid(fmt.Sprintf("time %d", params.GetTime()))
+	case params.GetMemory() < 8*params.GetThreads() || params.GetMemory() > MAX_ENCRYPTION_MEMORY:
+		return invalid(fmt.Sprintf("memory %d KiB", params.GetMemory()))
+	case params.GetThreads() < 1 || params.GetThreads() > 255:
+		return invalid(fmt.Sprintf("threads %d", params.GetThreads()))
+	case len(params.GetNoncePrefix()) != ENCRYPTION_NONCE_PREFIX_SIZE:
+		return invalid("nonce prefix has the wrong size")
+	case params.GetChunkSize() < 1 || params.GetChunkSize() > MAX_BLOCK_SIZE:
+		return invalid(fmt.Sprintf("chunk size %d", params.GetChunkSize()))
+	case len(params.GetKeyCheck()) != ENCRYPTION_KEY_CHECK_SIZE:
+		return invalid("key check has the wrong size")
+	case originalSize < 0:
+		return invalid(fmt.Sprintf("original size %d", originalSize))
+	}
+
+	sealedSize := originalSize + chunkCount(originalSize, int64(params.GetChunkSize()))*ENCRYPTION_OVERHEAD
+	if sealedSize != int64(available) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("encrypted content is %d bytes, expected %d", available, sealedSize),
+		}
+	}
+
+	return nil
+}
+
+// Decrypt opens content sealed by Encrypt, a wrong password gives a WrongPasswordError
+func Decrypt(content []byte, password []byte) ([]byte, error) {
+	return DecryptWithOptions(content, password, DecodeOptions{})
+}
+
+// DecryptWithOptions is Decrypt refusing to derive the key with more than opts.MaxMemory bytes,
+// the other options are not used
+func DecryptWithOptions(content []byte, password []byte, opts DecodeOptions) ([]byte, error) {
+	metaR, sealed, err := parseContainer(content)
+	if err != nil {
+		return nil, err
+	}
+
+	params := metaR.GetEncrypted()
+	if params == nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file is not encrypted",
+		}
+	}
+
+	if err := validateEncryption(params, metaR.GetOriginalSize(), len(sealed)); err != nil {
+		return nil, err
+	}
+
+	// checked before the key so a damaged salt or key check is not reported as a wrong password
+	header, err := encryptionHeader(metaR.GetOriginalSize(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(header) != params.GetHeaderChecksum() {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  "encryption header is corrupt",
+		}
+	}
+
+	// argon2 allocates all of its memory up front
+	if needed := int64(params.GetMemory()) * 1024; opts.MaxMemory > 0 && needed > opts.MaxMemory {
+		return nil, &sCError.LimitError{
+			Limit:  sCError.LIMIT_MEMORY,
+			Needed: needed,
+			Max:    opts.MaxMemory,
+		}
+	}
+
+	key, check := deriveKey(password, params)
+	if subtle.ConstantTimeCompare(check, params.GetKeyCheck()) != 1 {
+		return nil, &sCError.WrongPasswordError{}
+	}
+
+	aead, err := newChunkCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int(params.GetChunkSize()) + ENCRYPTION_OVERHEAD
+	chunks := int(chunkCount(metaR.GetOriginalSize(), int64(params.GetChunkSize())))
+	decrypted := make([]byte, 0, metaR.GetOriginalSize())
+	for idx := range chunks {
+		chunk := sealed[idx*chunkSize : min((idx+1)*chunkSize, len(sealed))]
+		decrypted, err = aead.Open(decrypted, chunkNonce(params, idx), chunk, chunkAdditionalData(header, idx, idx == chunks-1))
+		if err != nil {
+			return nil, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("encrypted chunk %d is corrupt or was tampered with", idx),
+			}
+		}
+	}
+
+	return decrypted, nil
+}
+
+// decoding needs the password first, the metadata of encrypted files has no blocks to decode
+func checkNotEncrypted(metaR *proto_data.CompressedFileMetaData) error {
+	if IsEncrypted(metaR) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file is encrypted, it has to be decrypted with its password first",
+		}
+	}
+
+	return nil
+}
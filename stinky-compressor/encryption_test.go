@@ -0,0 +1,193 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCanEncryptAndDecrypt(t *testing.T) {
+	input := []byte{}
+	for idx := range 500 {
+		input = fmt.Appendf(input, "%d: The ancient oak tree stood as a silent sentinel at the edge of the meadow.\n", idx)
+	}
+	password := []byte("correct horse battery staple")
+
+	encrypted, err := Compress(input, Options{Password: password})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if bytes.Contains(encrypted, []byte("meadow")) {
+		t.Fatalf("encrypted content contains plain text")
+	}
+
+	metaR, err := ParseMetadata(encrypted)
+	if err != nil {
+		t.Fatalf("ParseMetadata: %+v", err)
+	}
+
+	if !IsEncrypted(metaR) {
+		t.Fatalf("expected metadata to be marked as encrypted")
+	}
+
+	if _, err := DecodeCompressedFile(encrypted, false); err == nil {
+		t.Fatalf("expected error decoding encrypted content without decrypting it")
+	}
+
+	compressed, err := Decrypt(encrypted, password)
+	if err != nil {
+		t.Fatalf("Decrypt: %+v", err)
+	}
+
+	decoded, err := DecodeCompressedFile(compressed, false)
+	if err != nil {
+		t.Fatalf("DecodeCompressedFile: %+v", err)
+	}
+
+	if !bytes.Equal(decoded, input) {
+		t.Fatalf("decoded content does not match input")
+	}
+}
+
+func TestEncryptsEmptyInput(t *testing.T) {
+	encrypted, err := Encrypt([]byte{}, []byte("password"))
+	if err != nil {
+		t.Fatalf("Encrypt: %+v", err)
+	}
+
+	decrypted, err := Decrypt(encrypted, []byte("password"))
+	if err != nil || len(decrypted) != 0 {
+		t.Fatalf("unexpected decrypted content %q: %+v", decrypted, err)
+	}
+
+	if _, err := Encrypt([]byte("content"), nil); err == nil {
+		t.Fatalf("expected error encrypting with an empty password")
+	}
+}
+
+func TestDecryptTellsWrongPasswordFromCorruptData(t *testing.T) {
+	// spans several chunks so a swapped or dropped chunk can be tested too
+	input := make([]byte, 3*ENCRYPTION_CHUNK_SIZE+100)
+	for idx := range input {
+		input[idx] = byte(idx * 7)
+	}
+
+	encrypted, err := Encrypt(input, []byte("password"))
+	if err != nil {
+		t.Fatalf("Encrypt: %+v", err)
+	}
+
+	wrongPasswordErr := &sCError.WrongPasswordError{}
+	if _, err := Decrypt(encrypted, []byte("passw0rd")); !errors.As(err, &wrongPasswordErr) {
+		t.Fatalf("expected WrongPasswordError, got %+v", err)
+	}
+
+	chunkSize := ENCRYPTION_CHUNK_SIZE + ENCRYPTION_OVERHEAD
+	sealedStart := len(encrypted) - (len(input) + 4*ENCRYPTION_OVERHEAD)
+
+	flipped := bytes.Clone(encrypted)
+	flipped[len(flipped)-20] ^= 0x01
+
+	swapped := bytes.Clone(encrypted)
+	copy(swapped[sealedStart:], encrypted[sealedStart+chunkSize:sealedStart+2*chunkSize])
+	copy(swapped[sealedStart+chunkSize:], encrypted[sealedStart:sealedStart+chunkSize])
+
+	metaR, err := ParseMetadata(encrypted)
+	if err != nil {
+		t.Fatalf("ParseMetadata: %+v", err)
+	}
+
+	// the header fields keep their size so only the changed bytes are caught
+	corruptSalt := bytes.Clone(encrypted)
+	corruptSalt[bytes.Index(encrypted, metaR.GetEncrypted().GetSalt())] ^= 0x01
+
+	corruptKeyCheck := bytes.Clone(encrypted)
+	corruptKeyCheck[bytes.Index(encrypted, metaR.GetEncrypted().GetKeyCheck())] ^= 0x01
+
+	cases := map[string][]byte{
+		"flipped bit":       flipped,
+		"swapped chunks":    swapped,
+		"truncated":         encrypted[:len(encrypted)-1],
+		"dropped chunk":     append(bytes.Clone(encrypted[:sealedStart+2*chunkSize]), encrypted[sealedStart+3*chunkSize:]...),
+		"corrupt salt":      corruptSalt,
+		"corrupt key check": corruptKeyCheck,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decrypt(content, []byte("password"))
+			if err == nil {
+				t.Fatalf("expected error decrypting corrupt content")
+			}
+
+			if errors.As(err, &wrongPasswordErr) {
+				t.Fatalf("corrupt content reported as a wrong password: %+v", err)
+			}
+
+			if !errors.Is(err, sCError.ErrCorrupt) {
+				t.Fatalf("expected a corrupt error, got %+v", err)
+			}
+		})
+	}
+}
+
+func TestDecryptLimitsKeyDerivation(t *testing.T) {
+	encrypted, err := Encrypt([]byte("bobs burgers"), []byte("password"))
+	if err != nil {
+		t.Fatalf("Encrypt: %+v", err)
+	}
+
+	limitErr := &sCError.LimitError{}
+	_, err = DecryptWithOptions(encrypted, []byte("password"), DecodeOptions{MaxMemory: ENCRYPTION_MEMORY*1024 - 1})
+	if !errors.As(err, &limitErr) || limitErr.Limit != sCError.LIMIT_MEMORY {
+		t.Fatalf("expected a memory LimitError, got %+v", err)
+	}
+
+	decrypted, err := DecryptWithOptions(encrypted, []byte("password"), DecodeOptions{MaxMemory: ENCRYPTION_MEMORY * 1024})
+	if err != nil || string(decrypted) != "bobs burgers" {
+		t.Fatalf("unexpected decrypted content %q within the limit: %+v", decrypted, err)
+	}
+
+	// headers asking for more than a few times the default cost are rejected before deriving anything
+	metaR, sealed, err := parseContainer(encrypted)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	for name, params := range map[string]func(*Encryption){
+		"time":   func(params *Encryption) { params.Time = MAX_ENCRYPTION_TIME + 1 },
+		"memory": func(params *Encryption) { params.Memory = MAX_ENCRYPTION_MEMORY + 1 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			costly := proto.Clone(metaR).(*proto_data.CompressedFileMetaData)
+			params(costly.Encrypted)
+
+			metaBts, err := proto.Marshal(costly)
+			if err != nil {
+				t.Fatalf("proto.Marshal: %+v", err)
+			}
+
+			content := append(fmt.Appendf(nil, "%d#", len(metaBts)), metaBts...)
+			if _, err := Decrypt(append(content, sealed...), []byte("password")); !errors.Is(err, sCError.ErrCorrupt) {
+				t.Fatalf("expected a corrupt error, got %+v", err)
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsPlainCompressedFile(t *testing.T) {
+	compressed, err := Compress([]byte("bananas"), Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if _, err := Decrypt(compressed, []byte("password")); err == nil {
+		t.Fatalf("expected error decrypting content that is not encrypted")
+	}
+}
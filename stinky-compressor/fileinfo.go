@@ -0,0 +1,88 @@
+package stinkycompressor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"strings"
+	"time"
+)
+
+// CompressFile compresses like Compress and also stores the name, permissions and
+// modification time of the file input was read from so decoding can restore them
+func CompressFile(input []byte, info fs.FileInfo, opts Options) ([]byte, error) {
+	return CompressFileContext(context.Background(), input, info, opts)
+}
+
+// CompressFileContext compresses like CompressFile and stops once ctx is done
+func CompressFileContext(ctx context.Context, input []byte, info fs.FileInfo, opts Options) ([]byte, error) {
+	return compressWithMetadata(ctx, input, opts, &proto_data.CompressedFileMetaData{
+		Name:    info.Name(),
+		Mode:    uint32(info.Mode().Perm()),
+		ModTime: info.ModTime().UnixNano(),
+	})
+}
+
+// OriginalName is the stored name of the file that was compressed, ok is false when
+// there is none (stdin, archives and older files) or it is not a plain base name
+func OriginalName(metaR *proto_data.CompressedFileMetaData) (string, bool) {
+	name := metaR.GetName()
+	// a crafted name could otherwise point decoding outside of the destination directory
+	if name == "" || name == "." || !filepath.IsLocal(name) || filepath.Base(name) != name {
+		return "", false
+	}
+
+	return name, true
+}
+
+// DecompressedFileName is where a compressed file read from src decodes to by default,
+// its original name next to it, or src without the .stinkc extension for files without one
+func DecompressedFileName(src string, metaR *proto_data.CompressedFileMetaData) (string, error) {
+	if name, ok := OriginalName(metaR); ok {
+		return filepath.Join(filepath.Dir(src), name), nil
+	}
+
+	ext := "." + COMPRESSED_FILE_EXTENSION
+	if strings.HasSuffix(src, ext) && len(src) > len(ext) {
+		return strings.TrimSuffix(src, ext), nil
+	}
+
+	return "", &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_INVALID_ARGUMENT,
+		Message:  fmt.Sprintf("%s has no original file name stored, a destination is needed", src),
+	}
+}
+
+// RestoreFileInfo applies the stored permissions and modification time to a decoded file,
+// files compressed without them are left as they are
+func RestoreFileInfo(path string, metaR *proto_data.CompressedFileMetaData) error {
+	if metaR.GetName() == "" {
+		return nil
+	}
+
+	if err := os.Chmod(path, fs.FileMode(metaR.GetMode()).Perm()); err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to restore mode of %s", path),
+			Err:      err,
+		}
+	}
+
+	modTime := time.Unix(0, metaR.GetModTime())
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to restore modification time of %s", path),
+			Err:      err,
+		}
+	}
+
+	return nil
+}
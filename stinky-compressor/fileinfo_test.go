@@ -0,0 +1,97 @@
+package stinkycompressor
+
+import (
+	"os"
+	"path/filepath"
+	proto_data "stinky-compression/proto/proto-data"
+	"testing"
+	"time"
+)
+
+func TestCompressedFileNameKeepsExtension(t *testing.T) {
+	if CompressedFileName("dir/a.txt") == CompressedFileName("dir/a.md") {
+		t.Fatalf("a.txt and a.md compress to the same file %s", CompressedFileName("dir/a.txt"))
+	}
+
+	if got := CompressedFileName("dir/a.txt"); got != "dir/a.txt.stinkc" {
+		t.Fatalf("expected dir/a.txt.stinkc, got %s", got)
+	}
+}
+
+func TestCanRestoreOriginalFileInfo(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "notes.md")
+	input := []byte("bobs burgers and fried")
+	if err := os.WriteFile(src, input, 0o600); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes: %+v", err)
+	}
+
+	compressedFileName, err := WriteCompressionToFile(input, src, true, false, Options{})
+	if err != nil {
+		t.Fatalf("WriteCompressionToFile: %+v", err)
+	}
+
+	content, err := os.ReadFile(compressedFileName)
+	if err != nil {
+		t.Fatalf("ReadFile: %+v", err)
+	}
+
+	metaR, err := ParseMetadata(content)
+	if err != nil {
+		t.Fatalf("ParseMetadata: %+v", err)
+	}
+
+	dest, err := DecompressedFileName(compressedFileName, metaR)
+	if err != nil || dest != src {
+		t.Fatalf("expected to decode to %s, got %s: %+v", src, dest, err)
+	}
+
+	decoded, err := DecodeCompressedFile(content, false)
+	if err != nil {
+		t.Fatalf("DecodeCompressedFile: %+v", err)
+	}
+
+	if err := os.WriteFile(dest, decoded, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	if err := RestoreFileInfo(dest, metaR); err != nil {
+		t.Fatalf("RestoreFileInfo: %+v", err)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("Stat: %+v", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode -rw-------, got %s", info.Mode())
+	}
+
+	if !info.ModTime().Equal(modTime) {
+		t.Fatalf("expected modification time %s, got %s", modTime, info.ModTime())
+	}
+}
+
+func TestDecompressedFileNameIgnoresUnsafeNames(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../evil.txt", "/etc/passwd", "nested/evil.txt"} {
+		metaR := &proto_data.CompressedFileMetaData{Name: name}
+		if _, ok := OriginalName(metaR); ok {
+			t.Fatalf("expected %q to not be used as a file name", name)
+		}
+
+		dest, err := DecompressedFileName("dir/data.bin.stinkc", metaR)
+		if err != nil || dest != "dir/data.bin" {
+			t.Fatalf("expected fallback to dir/data.bin for %q, got %s: %+v", name, dest, err)
+		}
+	}
+
+	if _, err := DecompressedFileName("dir/data.bin", &proto_data.CompressedFileMetaData{}); err == nil {
+		t.Fatalf("expected error without a stored name or .stinkc extension")
+	}
+}
@@ -0,0 +1,161 @@
+package stinkycompressor
+
+import (
+	"fmt"
+	"stinky-compression/bwt"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+)
+
+const (
+	MIN_LEVEL     = 1
+	MAX_LEVEL     = 9
+	DEFAULT_LEVEL = 6
+)
+
+type Options struct {
+	// 1 is the fastest, 9 gives the best ratio, 0 means DEFAULT_LEVEL
+	Level int
+	// prints the huffman tree of every block
+	Debug bool
+	// blocks that code smaller with the dictionary table use it instead of their own, nil for none
+	Dictionary *Dictionary
+	// every block stores its own table even when a built in one would code it smaller
+	NoStaticTables bool
+	// the compressed file is encrypted with a key derived from it, nil for no encryption
+	Password []byte
+	// percent of the block data to add as reed solomon parity so damaged blocks can be repaired, 0 for none
+	Recovery int
+	// called as compression goes through the blocks, nil for no progress reporting
+	Progress ProgressFunc
+	// filled in with what every stage did once compression is done, nil to skip collecting them
+	Stats *Stats
+}
+
+// limits WithDefaultLimits fills in, what the CLI and stinkyhttp.Transport decode with unless told otherwise.
+// a crafted file of a few KB can claim to decode to any size, with these it can not take more than 2 GiB
+const (
+	DEFAULT_MAX_OUTPUT_SIZE = 1024 * 1024 * 1024
+	DEFAULT_MAX_BLOCK_SIZE  = MAX_BLOCK_SIZE
+	DEFAULT_MAX_MEMORY      = 2 * 1024 * 1024 * 1024
+)
+
+// decoding options, the limits make it safe to decode untrusted input and a zero or negative limit is not enforced.
+// going over a limit returns a LimitError before the memory is allocated
+type DecodeOptions struct {
+	// the dictionary content was compressed with, nil for none
+	Dictionary *Dictionary
+	// most bytes the whole content may decode to
+	MaxOutputSize int64
+	// largest block accepted, blocks above MAX_BLOCK_SIZE (MAX_LEGACY_BLOCK_SIZE for version 0) are always rejected as invalid
+	MaxBlockSize int64
+	// most memory decoding may take at once, the output decoded so far and what the current block needs
+	MaxMemory int64
+	// called as decoding goes through the blocks, nil for no progress reporting
+	Progress ProgressFunc
+}
+
+// WithDefaultLimits gives the limits that are zero their DEFAULT_MAX value, negative ones stay unlimited
+func (o DecodeOptions) WithDefaultLimits() DecodeOptions {
+	if o.MaxOutputSize == 0 {
+		o.MaxOutputSize = DEFAULT_MAX_OUTPUT_SIZE
+	}
+
+	if o.MaxBlockSize == 0 {
+		o.MaxBlockSize = DEFAULT_MAX_BLOCK_SIZE
+	}
+
+	if o.MaxMemory == 0 {
+		o.MaxMemory = DEFAULT_MAX_MEMORY
+	}
+
+	return o
+}
+
+type levelSettings struct {
+	blockSize   int
+	huffmanOnly bool
+	// most huffman tables a block is coded with, see huffman.OptimizeTables
+	tables int
+	// how many times the tables are rebuilt from the groups that picked them
+	iterations int
+}
+
+// bwt is by far the slowest step, the fast levels skip the whole bwt + rle + mft pipeline and huffman code raw bytes,
+// smaller blocks there adapt the code to local byte frequencies.
+// bigger bwt blocks give more context to group similar bytes together at the cost of a slower rotation sort,
+// more tables and iterations fit the code closer to the parts of the mft output that differ at the cost of time
+var levels = [MAX_LEVEL + 1]levelSettings{
+	1: {blockSize: 256 * 1024, huffmanOnly: true, tables: 1},
+	2: {blockSize: 64 * 1024, huffmanOnly: true, tables: 1},
+	3: {blockSize: 16 * 1024, huffmanOnly: true, tables: 1},
+	4: {blockSize: 32 * 1024, tables: 1},
+	5: {blockSize: 64 * 1024, tables: 2, iterations: 1},
+	6: {blockSize: BLOCK_SIZE, tables: 3, iterations: 2},
+	7: {blockSize: 256 * 1024, tables: 4, iterations: 3},
+	8: {blockSize: 512 * 1024, tables: 6, iterations: 4},
+	9: {blockSize: 900 * 1024, tables: 6, iterations: 4},
+}
+
+func (o Options) settings() (levelSettings, error) {
+	level := o.Level
+	if level == 0 {
+		level = DEFAULT_LEVEL
+	}
+
+	if level < MIN_LEVEL || level > MAX_LEVEL {
+		return levelSettings{}, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("compression level %d is not between %d and %d", o.Level, MIN_LEVEL, MAX_LEVEL),
+		}
+	}
+
+	if o.Recovery < 0 || o.Recovery > MAX_RECOVERY_PERCENT {
+		return levelSettings{}, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("recovery percent %d is not between 0 and %d", o.Recovery, MAX_RECOVERY_PERCENT),
+		}
+	}
+
+	// encrypted data is authenticated as a whole and parity inside of it can not be reached once it is damaged
+	if o.Recovery > 0 && len(o.Password) > 0 {
+		return levelSettings{}, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "recovery records can not be combined with encryption",
+		}
+	}
+
+	return levels[level], nil
+}
+
+// estimate of the memory a block needs while it is decoded, on top of the encoded data
+func blockMemory(block *proto_data.CompressedFileMetaData_Block) int64 {
+	if block.GetHuffmanOnly() {
+		return block.GetOriginalSize()
+	}
+
+	// huffman and mft output hold a symbol per rle count, the bwt inversion the rle output and more
+	return 2*int64(len(block.GetRleDict())) + bwt.DecodeMemory(int(block.GetOriginalSize())+1)
+}
+
+// checks the size a block claims before it is decoded, decodeBlock makes sure it does not decode to more.
+// decodedBefore is how much output is already held in memory
+func (o DecodeOptions) checkBlock(block *proto_data.CompressedFileMetaData_Block, decodedBefore int64) error {
+	size := max(block.GetOriginalSize(), 0)
+	if o.MaxBlockSize > 0 && size > o.MaxBlockSize {
+		return &sCError.LimitError{Limit: sCError.LIMIT_BLOCK_SIZE, Needed: size, Max: o.MaxBlockSize}
+	}
+
+	if o.MaxOutputSize > 0 && decodedBefore+size > o.MaxOutputSize {
+		return &sCError.LimitError{Limit: sCError.LIMIT_OUTPUT_SIZE, Needed: decodedBefore + size, Max: o.MaxOutputSize}
+	}
+
+	if memory := decodedBefore + blockMemory(block); o.MaxMemory > 0 && memory > o.MaxMemory {
+		return &sCError.LimitError{Limit: sCError.LIMIT_MEMORY, Needed: memory, Max: o.MaxMemory}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	sCError "stinky-compression/error"
+)
+
+func outputExistsError(filename string) error {
+	return &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_IO,
+		Message:  fmt.Sprintf("%s already exists, use -force to overwrite it", filename),
+		Err:      fs.ErrExist,
+	}
+}
+
+// WriteFileAtomic writes content to a temporary file next to filename and renames it over filename
+// once everything is synced to disk, so a failed write never leaves a partial or stale output behind.
+// Existing files are only replaced with force
+func WriteFileAtomic(filename string, content []byte, force bool) error {
+	return writeFileAtomic(filename, content, force, nil)
+}
+
+// check is called with the synced temporary file before it is renamed, filename is left alone when it fails
+func writeFileAtomic(filename string, content []byte, force bool, check func(tmpName string) error) error {
+	if _, err := os.Lstat(filename); err == nil && !force {
+		return outputExistsError(filename)
+	}
+
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, fmt.Sprintf(".%s.*.tmp", filepath.Base(filename)))
+	if err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to create temporary file for %s", filename),
+			Err:      err,
+		}
+	}
+
+	// a no-op once the rename went through
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// temporary files are created only readable by us
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to write %s", filename),
+			Err:      err,
+		}
+	}
+
+	if check != nil {
+		if err := check(tmp.Name()); err != nil {
+			return err
+		}
+	}
+
+	// checked again right before the rename to keep the window for a racing writer small
+	if _, err := os.Lstat(filename); err == nil && !force {
+		return outputExistsError(filename)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to move temporary file to %s", filename),
+			Err:      err,
+		}
+	}
+
+	// makes the rename itself durable, not every platform can sync a directory so errors are ignored
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+// reads back what was written to path and decodes it, the source is only removed once this passes.
+// compressedFileName is the name path is renamed to
+func verifyCompressedFile(ctx context.Context, path string, compressedFileName string, input []byte, opts Options) error {
+	written, err := os.ReadFile(path)
+	if err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to read back %s", compressedFileName),
+			Err:      err,
+		}
+	}
+
+	if len(opts.Password) > 0 {
+		written, err = Decrypt(written, opts.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	decoded, err := DecodeContext(ctx, written, DecodeOptions{Dictionary: opts.Dictionary})
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(decoded, input) {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("%s does not decode back to the original content", compressedFileName),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package stinkycompressor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomicRefusesToOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.stinkc")
+	previous := strings.Repeat("a much longer previous output ", 10)
+	if err := os.WriteFile(target, []byte(previous), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	if err := WriteFileAtomic(target, []byte("short"), false); err == nil {
+		t.Fatalf("expected error overwriting %s without force", target)
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != previous {
+		t.Fatalf("existing file was changed without force: %q", content)
+	}
+
+	if err := WriteFileAtomic(target, []byte("short"), true); err != nil {
+		t.Fatalf("WriteFileAtomic: %+v", err)
+	}
+
+	content, _ = os.ReadFile(target)
+	if string(content) != "short" {
+		t.Fatalf("expected only the new content without stale bytes, got %q", content)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected temporary files to be gone, dir has %d entries", len(entries))
+	}
+}
+
+func TestWriteFileAtomicLeavesOutputAloneWhenCheckFails(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.stinkc")
+	if err := os.WriteFile(target, []byte("previous"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	checked := ""
+	err := writeFileAtomic(target, []byte("does not decode"), true, func(tmpName string) error {
+		content, _ := os.ReadFile(tmpName)
+		checked = string(content)
+		return errors.New("verification failed")
+	})
+	if err == nil || checked != "does not decode" {
+		t.Fatalf("expected the check to fail on the written content, got %q: %+v", checked, err)
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "previous" {
+		t.Fatalf("output was replaced although the check failed: %q", content)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected temporary files to be gone, dir has %d entries", len(entries))
+	}
+}
+
+func TestWriteCompressionToFileKeepsSourceOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "notes.md")
+	input := []byte("bobs burgers and fried")
+	if err := os.WriteFile(src, input, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	if err := os.WriteFile(CompressedFileName(src), []byte("taken"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	if _, err := WriteCompressionToFile(input, src, true, false, Options{}); err == nil {
+		t.Fatalf("expected error when the compressed file already exists")
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		t.Fatalf("source was removed although nothing was written: %+v", err)
+	}
+
+	compressedFileName, err := WriteCompressionToFile(input, src, true, true, Options{})
+	if err != nil {
+		t.Fatalf("WriteCompressionToFile: %+v", err)
+	}
+
+	if _, err := os.Stat(src); err == nil {
+		t.Fatalf("expected source to be removed once the output was verified")
+	}
+
+	content, _ := os.ReadFile(compressedFileName)
+	decoded, err := DecodeCompressedFile(content, false)
+	if err != nil || string(decoded) != string(input) {
+		t.Fatalf("unexpected decoded content %q: %+v", decoded, err)
+	}
+}
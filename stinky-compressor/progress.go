@@ -0,0 +1,71 @@
+package stinkycompressor
+
+import (
+	"context"
+	sCError "stinky-compression/error"
+)
+
+// stages a Progress can report
+const (
+	STAGE_TRANSFORM = "bwt+rle+mft"
+	STAGE_HUFFMAN   = "huffman"
+	STAGE_RECOVERY  = "recovery"
+	STAGE_ENCRYPT   = "encrypt"
+	STAGE_DECODE    = "decode"
+	STAGE_DONE      = "done"
+)
+
+// what a ProgressFunc is called with at the start of every stage of every block
+type Progress struct {
+	Stage string
+	// index of the block being worked on out of Blocks
+	Block  int
+	Blocks int
+	// input consumed and output produced by the blocks before Block, Total is the size of the whole input.
+	// compressing reads the original content, decoding the encoded block data
+	BytesIn  int64
+	BytesOut int64
+	Total    int64
+}
+
+// called from the goroutine doing the work, it should return quickly
+type ProgressFunc func(Progress)
+
+// keeps the counts a ProgressFunc is called with, a nil func reports nothing
+type progressTracker struct {
+	fn       ProgressFunc
+	progress Progress
+}
+
+func newProgressTracker(fn ProgressFunc, blocks int, total int64) *progressTracker {
+	return &progressTracker{fn: fn, progress: Progress{Blocks: blocks, Total: total}}
+}
+
+func (t *progressTracker) report(stage string) {
+	if t.fn == nil {
+		return
+	}
+
+	t.progress.Stage = stage
+	t.fn(t.progress)
+}
+
+func (t *progressTracker) blockDone(in, out int64) {
+	t.progress.Block++
+	t.progress.BytesIn += in
+	t.progress.BytesOut += out
+}
+
+// ctx is checked between blocks and stages, a single stage of a block is not interrupted
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CANCELED,
+			Message:  "canceled",
+			Err:      err,
+		}
+	}
+
+	return nil
+}
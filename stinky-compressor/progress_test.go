@@ -0,0 +1,120 @@
+package stinkycompressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	sCError "stinky-compression/error"
+	"testing"
+)
+
+func helperProgressInput() []byte {
+	input := []byte{}
+	for idx := range 12000 {
+		input = fmt.Appendf(input, "%d bobs burgers and fried ", idx)
+	}
+
+	return input
+}
+
+func TestProgressIsReportedForEveryBlock(t *testing.T) {
+	input := helperProgressInput()
+	reports := []Progress{}
+
+	compressed, err := Compress(input, Options{Level: 4, Recovery: 10, Progress: func(progress Progress) {
+		reports = append(reports, progress)
+	}})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	blocks := (len(input) + levels[4].blockSize - 1) / levels[4].blockSize
+	// transform and huffman for every block, then recovery and done
+	if len(reports) != 2*blocks+2 {
+		t.Fatalf("expected %d reports for %d blocks, got %d", 2*blocks+2, blocks, len(reports))
+	}
+
+	for idx, report := range reports[1:] {
+		if report.BytesIn < reports[idx].BytesIn || report.Block < reports[idx].Block {
+			t.Fatalf("progress went backwards from %+v to %+v", reports[idx], report)
+		}
+	}
+
+	last := reports[len(reports)-1]
+	if last.Stage != STAGE_DONE || last.Block != blocks || last.BytesIn != int64(len(input)) || last.Total != int64(len(input)) {
+		t.Fatalf("expected the last report to be done with all input, got %+v", last)
+	}
+
+	if reports[len(reports)-2].Stage != STAGE_RECOVERY {
+		t.Fatalf("expected a recovery report before done, got %+v", reports[len(reports)-2])
+	}
+
+	decodeReports := []Progress{}
+	_, err = DecodeWithOptions(compressed, DecodeOptions{Progress: func(progress Progress) {
+		decodeReports = append(decodeReports, progress)
+	}})
+	if err != nil {
+		t.Fatalf("DecodeWithOptions: %+v", err)
+	}
+
+	last = decodeReports[len(decodeReports)-1]
+	if len(decodeReports) != blocks+1 || last.Stage != STAGE_DONE || last.BytesOut != int64(len(input)) || last.BytesIn != last.Total {
+		t.Fatalf("expected a decode report per block and done with all output, got %d reports ending with %+v", len(decodeReports), last)
+	}
+}
+
+func TestCompressStopsWhenCanceled(t *testing.T) {
+	input := helperProgressInput()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blocksStarted := 0
+	_, err := CompressContext(ctx, input, Options{Level: 4, Progress: func(progress Progress) {
+		if progress.Stage == STAGE_TRANSFORM {
+			blocksStarted++
+			cancel()
+		}
+	}})
+
+	if !errors.Is(err, sCError.ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled error, got %+v", err)
+	}
+
+	if blocksStarted != 1 {
+		t.Fatalf("expected compression to stop after the first block, %d were started", blocksStarted)
+	}
+
+	compressed, err := Compress(input, Options{Level: 4})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if _, err := DecodeContext(ctx, compressed, DecodeOptions{}); !errors.Is(err, sCError.ErrCanceled) {
+		t.Fatalf("expected canceled error decoding, got %+v", err)
+	}
+}
+
+func TestCanceledCompressionWritesNothing(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "input.txt")
+	input := helperProgressInput()
+	if err := os.WriteFile(filename, input, 0o644); err != nil {
+		t.Fatalf("WriteFile: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	compressedFileName, err := WriteCompressionToFileContext(ctx, input, filename, true, false, Options{})
+	if !errors.Is(err, sCError.ErrCanceled) {
+		t.Fatalf("expected canceled error, got %+v", err)
+	}
+
+	if _, err := os.Stat(compressedFileName); err == nil {
+		t.Fatalf("expected no compressed file after cancelling")
+	}
+
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatalf("expected the source to be kept: %+v", err)
+	}
+}
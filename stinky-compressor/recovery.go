@@ -0,0 +1,329 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"stinky-compression/reedsolomon"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MAX_RECOVERY_PERCENT = 100
+	// data shards per group, a group gets Percent of this many parity shards
+	RECOVERY_GROUP_SHARDS = 100
+	// shards grow with the file so small files do not need many of them and big files get more groups
+	MIN_RECOVERY_SHARD_SIZE = 64
+	MAX_RECOVERY_SHARD_SIZE = 64 * 1024
+	// the recovery record ends with its length and crc32 as little endian uint32s and this magic,
+	// it is found from the end of the container since the metadata it protects may be the damaged part
+	RECOVERY_MAGIC       = "STNKREC1"
+	RECOVERY_FOOTER_SIZE = 8 + len(RECOVERY_MAGIC)
+)
+
+type RecoveryRecord = proto_data.RecoveryRecord
+
+func recoveryShardSize(protectedLen int) int {
+	return min(max((protectedLen+RECOVERY_GROUP_SHARDS-1)/RECOVERY_GROUP_SHARDS, MIN_RECOVERY_SHARD_SIZE), MAX_RECOVERY_SHARD_SIZE)
+}
+
+func recoveryParityShards(dataShards, percent int) int {
+	return (dataShards*percent + 99) / 100
+}
+
+type shardGroup struct {
+	// data shards of the group
+	start, end int
+	// parity shards of the group, counted over the parity of all groups
+	parityStart, parityEnd int
+}
+
+type recoveryLayout struct {
+	shardSize    int
+	dataShards   int
+	parityShards int
+	groups       []shardGroup
+}
+
+func newRecoveryLayout(protectedLen, shardSize, percent int) recoveryLayout {
+	layout := recoveryLayout{shardSize: shardSize, dataShards: (protectedLen + shardSize - 1) / shardSize}
+	for start := 0; start < layout.dataShards; start += RECOVERY_GROUP_SHARDS {
+		group := shardGroup{start: start, end: min(start+RECOVERY_GROUP_SHARDS, layout.dataShards), parityStart: layout.parityShards}
+		group.parityEnd = group.parityStart + recoveryParityShards(group.end-group.start, percent)
+
+		layout.groups = append(layout.groups, group)
+		layout.parityShards = group.parityEnd
+	}
+
+	return layout
+}
+
+// the last shard is padded with zeros so every shard of a group is the same size
+func dataShard(protected []byte, idx, shardSize int) []byte {
+	shard := make([]byte, shardSize)
+	copy(shard, protected[min(idx*shardSize, len(protected)):min((idx+1)*shardSize, len(protected))])
+	return shard
+}
+
+// appends parity of everything written to content so far, followed by its record
+func writeRecovery(content *bytes.Buffer, percent int) error {
+	protected := bytes.Clone(content.Bytes())
+	shardSize := recoveryShardSize(len(protected))
+	layout := newRecoveryLayout(len(protected), shardSize, percent)
+
+	record := &RecoveryRecord{
+		ShardSize:    uint32(shardSize),
+		Percent:      uint32(percent),
+		ProtectedLen: int64(len(protected)),
+	}
+
+	for idx := range layout.dataShards {
+		record.ShardChecksums = append(record.ShardChecksums, crc32.ChecksumIEEE(dataShard(protected, idx, shardSize)))
+	}
+
+	for _, group := range layout.groups {
+		shards := [][]byte{}
+		for idx := group.start; idx < group.end; idx++ {
+			shards = append(shards, dataShard(protected, idx, shardSize))
+		}
+
+		parity, err := reedsolomon.Encode(shards, group.parityEnd-group.parityStart)
+		if err != nil {
+			return err
+		}
+
+		for _, shard := range parity {
+			record.ShardChecksums = append(record.ShardChecksums, crc32.ChecksumIEEE(shard))
+			content.Write(shard)
+		}
+	}
+
+	recordBts, err := proto.Marshal(record)
+	if err != nil {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INTERNAL,
+			Message:  "failed to marshal proto",
+			Err:      err,
+		}
+	}
+
+	content.Write(recordBts)
+	content.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(recordBts))))
+	content.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(recordBts)))
+	content.WriteString(RECOVERY_MAGIC)
+
+	return nil
+}
+
+// a recovery record found in a container, parity is where its parity starts and end where its footer ends
+type foundRecovery struct {
+	record *RecoveryRecord
+	layout recoveryLayout
+	parity int
+	end    int
+}
+
+func validateRecovery(record *RecoveryRecord, recordStart int) (recoveryLayout, error) {
+	invalid := func(message string) error {
+		return &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("invalid recovery record: %s", message),
+		}
+	}
+
+	shardSize := int(record.GetShardSize())
+	if shardSize < MIN_RECOVERY_SHARD_SIZE || shardSize > MAX_RECOVERY_SHARD_SIZE {
+		return recoveryLayout{}, invalid(fmt.Sprintf("shard size %d", shardSize))
+	}
+
+	percent := int(record.GetPercent())
+	if percent < 1 || percent > MAX_RECOVERY_PERCENT {
+		return recoveryLayout{}, invalid(fmt.Sprintf("percent %d", percent))
+	}
+
+	if record.GetProtectedLen() < 0 || record.GetProtectedLen() > int64(recordStart) {
+		return recoveryLayout{}, invalid(fmt.Sprintf("%d protected bytes before %d", record.GetProtectedLen(), recordStart))
+	}
+
+	layout := newRecoveryLayout(int(record.GetProtectedLen()), shardSize, percent)
+	if len(record.GetShardChecksums()) != layout.dataShards+layout.parityShards {
+		return recoveryLayout{}, invalid(fmt.Sprintf("%d shard checksums for %d shards", len(record.GetShardChecksums()), layout.dataShards+layout.parityShards))
+	}
+
+	if int(record.GetProtectedLen())+layout.parityShards*shardSize != recordStart {
+		return recoveryLayout{}, invalid("parity does not end where the record starts")
+	}
+
+	return layout, nil
+}
+
+// the last magic followed by a record matching its crc, earlier ones are tried in case the magic shows up in the parity
+func findRecovery(content []byte) (*foundRecovery, bool, error) {
+	end := len(content)
+	for {
+		magicIdx := bytes.LastIndex(content[:end], []byte(RECOVERY_MAGIC))
+		if magicIdx < 0 {
+			return nil, false, nil
+		}
+		end = magicIdx + len(RECOVERY_MAGIC) - 1
+
+		footerStart := magicIdx - 8
+		if footerStart < 0 {
+			continue
+		}
+
+		recordLen := int(binary.LittleEndian.Uint32(content[footerStart:]))
+		recordStart := footerStart - recordLen
+		if recordLen < 0 || recordStart < 0 {
+			continue
+		}
+
+		recordBts := content[recordStart:footerStart]
+		if crc32.ChecksumIEEE(recordBts) != binary.LittleEndian.Uint32(content[footerStart+4:]) {
+			continue
+		}
+
+		record := &RecoveryRecord{}
+		if err := proto.Unmarshal(recordBts, record); err != nil {
+			return nil, false, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  "failed to unmarshal recovery record",
+				Err:      err,
+			}
+		}
+
+		layout, err := validateRecovery(record, recordStart)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return &foundRecovery{
+			record: record,
+			layout: layout,
+			parity: int(record.GetProtectedLen()),
+			end:    magicIdx + len(RECOVERY_MAGIC),
+		}, true, nil
+	}
+}
+
+// rebuilds the shards that do not match their checksums, returns a repaired copy of the protected bytes
+// and the parity together with how many shards were rebuilt
+func repairShards(content []byte, found *foundRecovery) ([]byte, int, error) {
+	layout := found.layout
+	checksums := found.record.GetShardChecksums()
+	protected := content[:found.parity]
+	parity := content[found.parity : found.parity+layout.parityShards*layout.shardSize]
+
+	repaired := bytes.Clone(content[:found.parity+len(parity)])
+	rebuilt := 0
+	for groupIdx, group := range layout.groups {
+		shards := [][]byte{}
+		damaged := 0
+		for idx := group.start; idx < group.end; idx++ {
+			shard := dataShard(protected, idx, layout.shardSize)
+			if crc32.ChecksumIEEE(shard) != checksums[idx] {
+				shard = nil
+				damaged++
+			}
+
+			shards = append(shards, shard)
+		}
+
+		for idx := group.parityStart; idx < group.parityEnd; idx++ {
+			shard := parity[idx*layout.shardSize : (idx+1)*layout.shardSize]
+			if crc32.ChecksumIEEE(shard) != checksums[layout.dataShards+idx] {
+				shard = nil
+				damaged++
+			}
+
+			shards = append(shards, shard)
+		}
+
+		if damaged == 0 {
+			continue
+		}
+
+		if err := reedsolomon.Reconstruct(shards, group.end-group.start); err != nil {
+			return nil, 0, &sCError.CompressorError{
+				Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+				Code:     sCError.CODE_CORRUPT,
+				Message:  fmt.Sprintf("failed to repair shard group %d, %d of its %d shards are damaged", groupIdx, damaged, len(shards)),
+				Err:      err,
+			}
+		}
+
+		for idx := group.start; idx < group.end; idx++ {
+			copy(repaired[idx*layout.shardSize:min((idx+1)*layout.shardSize, len(protected))], shards[idx-group.start])
+		}
+
+		for idx := group.parityStart; idx < group.parityEnd; idx++ {
+			copy(repaired[len(protected)+idx*layout.shardSize:], shards[group.end-group.start+idx-group.parityStart])
+		}
+
+		rebuilt += damaged
+	}
+
+	return repaired, rebuilt, nil
+}
+
+// ok is false when content has no recovery record
+func repairContainer(content []byte) ([]byte, int, bool, error) {
+	found, ok, err := findRecovery(content)
+	if err != nil || !ok {
+		return nil, 0, ok, err
+	}
+
+	repaired, rebuilt, err := repairShards(content, found)
+	if err != nil {
+		return nil, 0, true, err
+	}
+
+	metaR, binData, err := parseContainer(repaired)
+	if err != nil {
+		return nil, 0, true, err
+	}
+
+	// the block index is not covered by the parity, it is written again from the repaired metadata
+	result := bytes.NewBuffer(make([]byte, 0, len(content)))
+	result.Write(repaired)
+	result.Write(content[len(repaired):found.end])
+	writeBlockIndex(result, Blocks(metaR), int64(len(repaired)-len(binData)))
+
+	return result.Bytes(), rebuilt, true, nil
+}
+
+// Repair rebuilds the damaged parts of content from its recovery record and returns the repaired content
+// with how many shards were rebuilt. the recovery record at the end of content has to be intact
+func Repair(content []byte) ([]byte, int, error) {
+	repaired, rebuilt, ok, err := repairContainer(content)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !ok {
+		return nil, 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  "compressed file has no recovery record",
+		}
+	}
+
+	return repaired, rebuilt, nil
+}
+
+// RecoveryInfo returns the recovery record of content and how many bytes its parity and record take up
+func RecoveryInfo(content []byte) (*RecoveryRecord, int64, bool) {
+	found, ok, err := findRecovery(content)
+	if err != nil || !ok {
+		return nil, 0, false
+	}
+
+	return found.record, int64(found.end - found.parity), true
+}
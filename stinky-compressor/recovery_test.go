@@ -0,0 +1,140 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	sCError "stinky-compression/error"
+	"strings"
+	"testing"
+)
+
+func helperRecoverableInput(t *testing.T, percent int) ([]byte, []byte, int) {
+	rnd := rand.New(rand.NewPCG(5, 6))
+	input := make([]byte, 32*1024*3+1000)
+	for idx := range input {
+		input[idx] = byte('a' + rnd.IntN(8))
+	}
+
+	compressed, err := Compress(input, Options{Level: 4, Recovery: percent})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	_, binData, err := parseContainer(compressed)
+	if err != nil {
+		t.Fatalf("parseContainer: %+v", err)
+	}
+
+	return input, compressed, len(compressed) - len(binData)
+}
+
+func TestDecodeRepairsDamagedBlocks(t *testing.T) {
+	cases := map[string]struct {
+		percent int
+		damage  func(content []byte, dataStart int)
+	}{
+		"flipped bytes": {percent: 5, damage: func(content []byte, dataStart int) {
+			content[dataStart+10] ^= 0xff
+			content[dataStart+20000] ^= 0x01
+		}},
+		"damaged metadata": {percent: 5, damage: func(content []byte, dataStart int) {
+			copy(content[2:], "garbage")
+			content[dataStart-50] ^= 0x10
+		}},
+		"lost block": {percent: 40, damage: func(content []byte, dataStart int) {
+			metaR, _ := ParseMetadata(content)
+			secondBlock := dataStart + int(Blocks(metaR)[0].GetEncodedLen())
+			clear(content[secondBlock : secondBlock+int(Blocks(metaR)[1].GetEncodedLen())])
+		}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			input, compressed, dataStart := helperRecoverableInput(t, tc.percent)
+			tc.damage(compressed, dataStart)
+
+			decoded, err := DecodeCompressedFile(compressed, false)
+			if err != nil {
+				t.Fatalf("DecodeCompressedFile: %+v", err)
+			}
+
+			if !bytes.Equal(decoded, input) {
+				t.Fatalf("repaired content does not match input")
+			}
+		})
+	}
+}
+
+func TestRepairRestoresDamagedFile(t *testing.T) {
+	_, compressed, dataStart := helperRecoverableInput(t, 10)
+	_, parity, _ := RecoveryInfo(compressed)
+
+	damaged := bytes.Clone(compressed)
+	damaged[dataStart+100] ^= 0x55
+	// somewhere in the parity and in the block index that is not covered by it
+	damaged[len(damaged)-int(parity)] ^= 0x55
+	clear(damaged[len(damaged)-INDEX_FOOTER_SIZE-2*INDEX_ENTRY_SIZE : len(damaged)-INDEX_FOOTER_SIZE])
+
+	repaired, rebuilt, err := Repair(damaged)
+	if err != nil {
+		t.Fatalf("Repair: %+v", err)
+	}
+
+	if rebuilt != 2 {
+		t.Fatalf("expected a data shard and a parity shard to be rebuilt, got %d", rebuilt)
+	}
+
+	if !bytes.Equal(repaired, compressed) {
+		t.Fatalf("repaired file does not match the original")
+	}
+
+	if _, rebuilt, err := Repair(compressed); err != nil || rebuilt != 0 {
+		t.Fatalf("expected nothing to repair in an intact file, got %d: %+v", rebuilt, err)
+	}
+}
+
+func TestRepairFailsWithTooMuchDamage(t *testing.T) {
+	_, compressed, dataStart := helperRecoverableInput(t, 1)
+	clear(compressed[dataStart : dataStart+5000])
+
+	if _, _, err := Repair(compressed); err == nil {
+		t.Fatalf("expected error repairing more damage than there is parity for")
+	}
+
+	_, damageErr := decodeContainer(context.Background(), compressed, DecodeOptions{})
+	if damageErr == nil {
+		t.Fatalf("expected error decoding the damaged blocks")
+	}
+
+	// the damage is reported, not only that the repair failed
+	_, err := DecodeCompressedFile(compressed, false)
+	if err == nil || !strings.Contains(err.Error(), damageErr.Error()) || sCError.Code(err) != sCError.Code(damageErr) {
+		t.Fatalf("expected the decode error %+v to be kept, got %+v", damageErr, err)
+	}
+
+	plain, err := Compress([]byte("bananas"), Options{})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	if _, _, err := Repair(plain); err == nil {
+		t.Fatalf("expected error repairing a file without recovery record")
+	}
+}
+
+func TestCompressRejectsInvalidRecovery(t *testing.T) {
+	cases := map[string]Options{
+		"negative":        {Recovery: -1},
+		"too much":        {Recovery: MAX_RECOVERY_PERCENT + 1},
+		"with encryption": {Recovery: 10, Password: []byte("password")},
+	}
+
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Compress([]byte("bananas"), opts); err == nil {
+				t.Fatalf("expected error for %+v", opts)
+			}
+		})
+	}
+}
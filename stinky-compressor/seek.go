@@ -0,0 +1,371 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	"sync"
+)
+
+const (
+	// every index entry is the uncompressed offset and the container offset of a block as little endian uint64s
+	INDEX_ENTRY_SIZE = 16
+	// the index ends with its entry count and this magic so readers can find it from the end of the container
+	INDEX_MAGIC       = "STNKIDX1"
+	INDEX_FOOTER_SIZE = 8 + len(INDEX_MAGIC)
+)
+
+type indexEntry struct {
+	uncompressedOffset int64
+	// where the encoded bits of the block start in the container
+	offset int64
+}
+
+func writeBlockIndex(content *bytes.Buffer, blocks []*proto_data.CompressedFileMetaData_Block, dataStart int64) {
+	for _, entry := range indexFromBlocks(blocks, dataStart) {
+		content.Write(binary.LittleEndian.AppendUint64(nil, uint64(entry.uncompressedOffset)))
+		content.Write(binary.LittleEndian.AppendUint64(nil, uint64(entry.offset)))
+	}
+
+	content.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(blocks))))
+	content.WriteString(INDEX_MAGIC)
+}
+
+// blocks are stored one after another, files written before the index existed get it from their metadata
+func indexFromBlocks(blocks []*proto_data.CompressedFileMetaData_Block, dataStart int64) []indexEntry {
+	index := make([]indexEntry, 0, len(blocks))
+	uncompressedOffset, offset := int64(0), dataStart
+	for _, block := range blocks {
+		index = append(index, indexEntry{uncompressedOffset: uncompressedOffset, offset: offset})
+		uncompressedOffset += block.GetOriginalSize()
+		offset += block.GetEncodedLen()
+	}
+
+	return index
+}
+
+func invalidIndexError(message string) error {
+	return &sCError.CompressorError{
+		Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+		Code:     sCError.CODE_CORRUPT,
+		Message:  fmt.Sprintf("invalid block index: %s", message),
+	}
+}
+
+// reads the index from the end of the container, ok is false when the container has none
+func readBlockIndex(src io.ReaderAt, size int64, blocks []*proto_data.CompressedFileMetaData_Block, dataStart int64) ([]indexEntry, bool, error) {
+	if size-dataStart < int64(INDEX_FOOTER_SIZE) {
+		return nil, false, nil
+	}
+
+	footer := make([]byte, INDEX_FOOTER_SIZE)
+	if _, err := src.ReadAt(footer, size-int64(INDEX_FOOTER_SIZE)); err != nil {
+		return nil, false, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read block index footer",
+			Err:      err,
+		}
+	}
+
+	if string(footer[8:]) != INDEX_MAGIC {
+		return nil, false, nil
+	}
+
+	count := binary.LittleEndian.Uint64(footer[:8])
+	if count != uint64(len(blocks)) {
+		return nil, false, invalidIndexError(fmt.Sprintf("%d entries for %d blocks", count, len(blocks)))
+	}
+
+	indexStart := size - int64(INDEX_FOOTER_SIZE) - int64(len(blocks))*INDEX_ENTRY_SIZE
+	if indexStart < dataStart {
+		return nil, false, invalidIndexError("does not fit in the container")
+	}
+
+	raw := make([]byte, len(blocks)*INDEX_ENTRY_SIZE)
+	if _, err := src.ReadAt(raw, indexStart); err != nil {
+		return nil, false, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read block index entries",
+			Err:      err,
+		}
+	}
+
+	index := make([]indexEntry, 0, len(blocks))
+	uncompressedOffset := int64(0)
+	for idx, block := range blocks {
+		entry := indexEntry{
+			uncompressedOffset: int64(binary.LittleEndian.Uint64(raw[idx*INDEX_ENTRY_SIZE:])),
+			offset:             int64(binary.LittleEndian.Uint64(raw[idx*INDEX_ENTRY_SIZE+8:])),
+		}
+
+		if entry.uncompressedOffset != uncompressedOffset {
+			return nil, false, invalidIndexError(fmt.Sprintf("block %d starts at %d, expected %d", idx, entry.uncompressedOffset, uncompressedOffset))
+		}
+
+		if entry.offset < dataStart || block.GetEncodedLen() < 0 || entry.offset > indexStart-block.GetEncodedLen() {
+			return nil, false, invalidIndexError(fmt.Sprintf("block %d at %d does not fit in the container", idx, entry.offset))
+		}
+
+		index = append(index, entry)
+		uncompressedOffset += block.GetOriginalSize()
+	}
+
+	return index, true, nil
+}
+
+// Reader decodes byte ranges of a compressed file, only the blocks covering a range are read and decoded.
+// It implements io.ReaderAt, which is safe for parallel use, and io.ReadSeeker, which is not
+type Reader struct {
+	src             io.ReaderAt
+	srcSize         int64
+	metaR           *proto_data.CompressedFileMetaData
+	blocks          []*proto_data.CompressedFileMetaData_Block
+	index           []indexEntry
+	size            int64
+	verifyChecksums bool
+	opts            DecodeOptions
+
+	// the last decoded block, sequential reads usually hit the same block many times in a row
+	cacheLock sync.Mutex
+	cachedIdx int
+	cached    []byte
+
+	offset int64
+}
+
+// NewReader reads the metadata and block index of the compressed file in src, size is the length of src
+func NewReader(src io.ReaderAt, size int64) (*Reader, error) {
+	return NewReaderWithDictionary(src, size, nil)
+}
+
+// NewReaderWithDictionary is NewReader for files compressed with dict
+func NewReaderWithDictionary(src io.ReaderAt, size int64, dict *Dictionary) (*Reader, error) {
+	return NewReaderWithOptions(src, size, DecodeOptions{Dictionary: dict})
+}
+
+// NewReaderWithOptions is NewReader decoding every block within the limits of opts,
+// MaxOutputSize and MaxMemory apply to a single block since only one is held at a time
+func NewReaderWithOptions(src io.ReaderAt, size int64, opts DecodeOptions) (*Reader, error) {
+	prefix := make([]byte, min(int64(MAX_META_SIZE_DIGITS+1), size))
+	if _, err := src.ReadAt(prefix, 0); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read meta size",
+			Err:      err,
+		}
+	}
+
+	metaStartIdx, metaSize, err := parseMetaSize(prefix, int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	metaBts := make([]byte, metaSize)
+	if _, err := src.ReadAt(metaBts, int64(metaStartIdx)); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read meta bytes",
+			Err:      err,
+		}
+	}
+
+	metaR, err := unmarshalMetadata(metaBts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotEncrypted(metaR); err != nil {
+		return nil, err
+	}
+
+	if err := checkDictionary(metaR, opts.Dictionary); err != nil {
+		return nil, err
+	}
+
+	blocks := Blocks(metaR)
+	dataStart := int64(metaStartIdx + metaSize)
+
+	index, ok, err := readBlockIndex(src, size, blocks, dataStart)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		index = indexFromBlocks(blocks, dataStart)
+	}
+
+	decodedSize := int64(0)
+	for _, block := range blocks {
+		decodedSize += block.GetOriginalSize()
+	}
+
+	if decodedSize != metaR.GetOriginalSize() {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("blocks decode to %d bytes, expected %d", decodedSize, metaR.GetOriginalSize()),
+		}
+	}
+
+	return &Reader{
+		src:             src,
+		srcSize:         size,
+		metaR:           metaR,
+		blocks:          blocks,
+		index:           index,
+		size:            decodedSize,
+		verifyChecksums: metaR.GetVersion() >= CONTAINER_VERSION,
+		opts:            opts,
+		cachedIdx:       -1,
+	}, nil
+}
+
+// Size is the length of the decoded content
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Metadata() *proto_data.CompressedFileMetaData {
+	return r.metaR
+}
+
+func (r *Reader) decodeBlockAt(idx int) ([]byte, error) {
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+
+	if r.cachedIdx == idx {
+		return r.cached, nil
+	}
+
+	block := r.blocks[idx]
+	offset := r.index[idx].offset
+	if block.GetEncodedLen() < 0 || offset < 0 || offset > r.srcSize-block.GetEncodedLen() {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block %d at %d does not fit in %d bytes of content", idx, offset, r.srcSize),
+		}
+	}
+
+	if err := r.opts.checkBlock(block, 0); err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, block.GetEncodedLen())
+	if _, err := r.src.ReadAt(encoded, offset); err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  fmt.Sprintf("failed to read block %d", idx),
+			Err:      err,
+		}
+	}
+
+	decoded, err := decodeBlock(block, encoded, maxBlockSize(r.metaR), r.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(decoded)) != block.GetOriginalSize() {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_CORRUPT,
+			Message:  fmt.Sprintf("block %d decodes to %d bytes, expected %d", idx, len(decoded), block.GetOriginalSize()),
+		}
+	}
+
+	if r.verifyChecksums {
+		checksum := crc32.ChecksumIEEE(decoded)
+		if checksum != block.GetChecksum() {
+			return nil, &sCError.ChecksumError{
+				Block:    idx,
+				Expected: block.GetChecksum(),
+				Actual:   checksum,
+			}
+		}
+	}
+
+	r.cachedIdx, r.cached = idx, decoded
+	return decoded, nil
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("negative offset %d", off),
+		}
+	}
+
+	read := 0
+	for read < len(p) && off < r.size {
+		// the last block starting at or before off, empty blocks share their start with the next one
+		idx := sort.Search(len(r.index), func(idx int) bool {
+			return r.index[idx].uncompressedOffset > off
+		}) - 1
+
+		decoded, err := r.decodeBlockAt(idx)
+		if err != nil {
+			return read, err
+		}
+
+		copied := copy(p[read:], decoded[off-r.index[idx].uncompressedOffset:])
+		read += copied
+		off += int64(copied)
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	read, err := r.ReadAt(p, r.offset)
+	r.offset += int64(read)
+
+	if read > 0 && errors.Is(err, io.EOF) {
+		return read, nil
+	}
+
+	return read, err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.offset, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("invalid whence %d", whence),
+		}
+	}
+
+	if offset < 0 {
+		return r.offset, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_INVALID_ARGUMENT,
+			Message:  fmt.Sprintf("negative position %d", offset),
+		}
+	}
+
+	r.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,151 @@
+package stinkycompressor
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+// remembers which parts of the container were read
+type recordingReaderAt struct {
+	src   *bytes.Reader
+	lock  sync.Mutex
+	reads [][2]int64
+}
+
+func (r *recordingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.lock.Lock()
+	r.reads = append(r.reads, [2]int64{off, off + int64(len(p))})
+	r.lock.Unlock()
+
+	return r.src.ReadAt(p, off)
+}
+
+func helperSeekableInput(t *testing.T) ([]byte, []byte) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+	// level 4 blocks are 32K so this is four blocks, the last one partial
+	input := make([]byte, 32*1024*3+1000)
+	for idx := range input {
+		input[idx] = byte('a' + rnd.IntN(8))
+	}
+
+	compressed, err := Compress(input, Options{Level: 4})
+	if err != nil {
+		t.Fatalf("Compress: %+v", err)
+	}
+
+	return input, compressed
+}
+
+func TestReaderReadsRanges(t *testing.T) {
+	input, compressed := helperSeekableInput(t)
+
+	src := &recordingReaderAt{src: bytes.NewReader(compressed)}
+	reader, err := NewReader(src, int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %+v", err)
+	}
+
+	if reader.Size() != int64(len(input)) {
+		t.Fatalf("expected size %d, got %d", len(input), reader.Size())
+	}
+
+	ranges := [][2]int64{{0, 10}, {32*1024 - 5, 10}, {40000, 1}, {32 * 1024 * 3, 1000}, {1000, 32*1024*2 + 7}}
+	for _, rng := range ranges {
+		got := make([]byte, rng[1])
+		n, err := reader.ReadAt(got, rng[0])
+		if err != nil || n != len(got) {
+			t.Fatalf("ReadAt(%d, %d) read %d: %+v", rng[0], rng[1], n, err)
+		}
+
+		if !bytes.Equal(got, input[rng[0]:rng[0]+rng[1]]) {
+			t.Fatalf("ReadAt(%d, %d) did not match input", rng[0], rng[1])
+		}
+	}
+
+	n, err := reader.ReadAt(make([]byte, 20), int64(len(input)-10))
+	if n != 10 || err != io.EOF {
+		t.Fatalf("expected 10 bytes and io.EOF at the end, got %d: %+v", n, err)
+	}
+
+	// a range inside the third block must not read the encoded bits of the first two
+	reader, err = NewReader(src, int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %+v", err)
+	}
+
+	src.reads = nil
+	if _, err := reader.ReadAt(make([]byte, 100), 32*1024*2+50); err != nil {
+		t.Fatalf("ReadAt: %+v", err)
+	}
+
+	if len(src.reads) != 1 || src.reads[0][0] != reader.index[2].offset {
+		t.Fatalf("expected a single read of block 2 at %d, got %v", reader.index[2].offset, src.reads)
+	}
+}
+
+func TestReaderSeeks(t *testing.T) {
+	input, compressed := helperSeekableInput(t)
+
+	reader, err := NewReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %+v", err)
+	}
+
+	if _, err := reader.Seek(-500, io.SeekEnd); err != nil {
+		t.Fatalf("Seek: %+v", err)
+	}
+
+	tail, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(tail, input[len(input)-500:]) {
+		t.Fatalf("reading after Seek did not match input: %+v", err)
+	}
+
+	if _, err := reader.Seek(-1, io.SeekStart); err == nil {
+		t.Fatalf("expected error seeking before the start")
+	}
+}
+
+func TestReaderWorksWithoutIndex(t *testing.T) {
+	input, compressed := helperSeekableInput(t)
+	withoutIndex := compressed[:len(compressed)-INDEX_FOOTER_SIZE-4*INDEX_ENTRY_SIZE]
+
+	reader, err := NewReader(bytes.NewReader(withoutIndex), int64(len(withoutIndex)))
+	if err != nil {
+		t.Fatalf("NewReader: %+v", err)
+	}
+
+	got := make([]byte, 64)
+	if _, err := reader.ReadAt(got, 70000); err != nil || !bytes.Equal(got, input[70000:70064]) {
+		t.Fatalf("ReadAt without index did not match input: %+v", err)
+	}
+}
+
+func TestReaderRejectsCorruptIndex(t *testing.T) {
+	_, compressed := helperSeekableInput(t)
+
+	cases := map[string]func(content []byte){
+		"wrong count": func(content []byte) {
+			content[len(content)-INDEX_FOOTER_SIZE]++
+		},
+		"offset outside container": func(content []byte) {
+			content[len(content)-INDEX_FOOTER_SIZE-1] = 0x7f
+		},
+		"wrong uncompressed offset": func(content []byte) {
+			content[len(content)-INDEX_FOOTER_SIZE-INDEX_ENTRY_SIZE]++
+		},
+	}
+
+	for name, corrupt := range cases {
+		t.Run(name, func(t *testing.T) {
+			content := bytes.Clone(compressed)
+			corrupt(content)
+
+			if _, err := NewReader(bytes.NewReader(content), int64(len(content))); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
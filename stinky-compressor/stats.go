@@ -0,0 +1,170 @@
+package stinkycompressor
+
+import (
+	"math"
+	"stinky-compression/huffman"
+	"time"
+)
+
+// what happened to a single block in every stage of the pipeline, the bwt, rle and mft fields stay empty
+// for huffman only blocks and the entropy is then the one of the raw bytes
+type BlockStats struct {
+	OriginalSize int64 `json:"original_size"`
+	HuffmanOnly  bool  `json:"huffman_only"`
+	// runs of equal bytes before and after the bwt, rle turns every run into a single byte
+	InputRuns int `json:"input_runs"`
+	BwtRuns   int `json:"bwt_runs"`
+	// bytes left after rle and how many counts it stored for them
+	RleSize    int     `json:"rle_size"`
+	RleDictLen int     `json:"rle_dict_len"`
+	RleRatio   float64 `json:"rle_ratio"`
+	// fraction of the mft output that is 0
+	MftZeroFraction float64 `json:"mft_zero_fraction"`
+	// shannon entropy of the huffman coded symbols in bits per symbol, the least any order 0 coder can do,
+	// against the bits per symbol the huffman code actually took
+	Symbols       int     `json:"symbols"`
+	Entropy       float64 `json:"entropy"`
+	BitsPerSymbol float64 `json:"bits_per_symbol"`
+	EncodedSize   int64   `json:"encoded_size"`
+	// "own", "dictionary" or "static <name>"
+	Table string `json:"table"`
+
+	BwtTime     time.Duration `json:"bwt_ns"`
+	RleTime     time.Duration `json:"rle_ns"`
+	MftTime     time.Duration `json:"mft_ns"`
+	HuffmanTime time.Duration `json:"huffman_ns"`
+}
+
+// statistics of a whole compression, the sizes are in bytes.
+// Overhead is everything that is not encoded block data: the size prefix, metadata, index, recovery and encryption
+type Stats struct {
+	OriginalSize   int64   `json:"original_size"`
+	CompressedSize int64   `json:"compressed_size"`
+	EncodedSize    int64   `json:"encoded_size"`
+	MetadataSize   int64   `json:"metadata_size"`
+	RecoverySize   int64   `json:"recovery_size"`
+	Overhead       int64   `json:"overhead"`
+	Ratio          float64 `json:"ratio"`
+
+	// totals over the blocks, entropy and bits per symbol are weighted by the symbols of every block
+	BwtRuns         int     `json:"bwt_runs"`
+	InputRuns       int     `json:"input_runs"`
+	RleSize         int     `json:"rle_size"`
+	RleDictLen      int     `json:"rle_dict_len"`
+	RleRatio        float64 `json:"rle_ratio"`
+	MftZeroFraction float64 `json:"mft_zero_fraction"`
+	Symbols         int     `json:"symbols"`
+	Entropy         float64 `json:"entropy"`
+	BitsPerSymbol   float64 `json:"bits_per_symbol"`
+
+	BwtTime      time.Duration `json:"bwt_ns"`
+	RleTime      time.Duration `json:"rle_ns"`
+	MftTime      time.Duration `json:"mft_ns"`
+	HuffmanTime  time.Duration `json:"huffman_ns"`
+	RecoveryTime time.Duration `json:"recovery_ns"`
+	EncryptTime  time.Duration `json:"encrypt_ns"`
+	TotalTime    time.Duration `json:"total_ns"`
+
+	Blocks []BlockStats `json:"blocks"`
+}
+
+// CompressWithStats compresses like Compress and also returns what every stage did
+func CompressWithStats(input []byte, opts Options) ([]byte, *Stats, error) {
+	stats := &Stats{}
+	opts.Stats = stats
+
+	compressed, err := Compress(input, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return compressed, stats, nil
+}
+
+func countRuns(input []byte) int {
+	runs := 0
+	for idx := range input {
+		if idx == 0 || input[idx] != input[idx-1] {
+			runs++
+		}
+	}
+
+	return runs
+}
+
+func zeroFraction(input []byte) float64 {
+	if len(input) == 0 {
+		return 0
+	}
+
+	zeros := 0
+	for _, bt := range input {
+		if bt == 0 {
+			zeros++
+		}
+	}
+
+	return float64(zeros) / float64(len(input))
+}
+
+func entropy(occurrences huffman.FrequencyTable) float64 {
+	total := 0
+	for _, count := range occurrences {
+		total += count
+	}
+
+	bits := 0.0
+	for _, count := range occurrences {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			bits -= p * math.Log2(p)
+		}
+	}
+
+	return bits
+}
+
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(whole)
+}
+
+// sums up the blocks once all of them are added
+func (s *Stats) total() {
+	weightedEntropy, bits, mftZeros, bwtSize := 0.0, int64(0), 0.0, int64(0)
+	for _, block := range s.Blocks {
+		s.InputRuns += block.InputRuns
+		s.BwtRuns += block.BwtRuns
+		s.RleSize += block.RleSize
+		s.RleDictLen += block.RleDictLen
+		s.Symbols += block.Symbols
+		s.BwtTime += block.BwtTime
+		s.RleTime += block.RleTime
+		s.MftTime += block.MftTime
+		s.HuffmanTime += block.HuffmanTime
+
+		weightedEntropy += block.Entropy * float64(block.Symbols)
+		bits += int64(math.Round(block.BitsPerSymbol * float64(block.Symbols)))
+		if !block.HuffmanOnly {
+			mftZeros += block.MftZeroFraction * float64(block.RleSize)
+			// the bwt output carries the primary index marker on top of the block
+			bwtSize += block.OriginalSize + 1
+		}
+	}
+
+	if s.Symbols > 0 {
+		s.Entropy = weightedEntropy / float64(s.Symbols)
+		s.BitsPerSymbol = float64(bits) / float64(s.Symbols)
+	}
+
+	s.RleRatio = ratio(int64(s.RleSize), bwtSize)
+	if s.RleSize > 0 {
+		s.MftZeroFraction = mftZeros / float64(s.RleSize)
+	}
+
+	s.Overhead = s.CompressedSize - s.EncodedSize
+	s.Ratio = ratio(s.CompressedSize, s.OriginalSize)
+}
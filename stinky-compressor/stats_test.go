@@ -0,0 +1,65 @@
+package stinkycompressor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompressWithStatsMatchesOutput(t *testing.T) {
+	input := []byte{}
+	for idx := range 6000 {
+		input = fmt.Appendf(input, "%d bobs burgers and fried ", idx)
+	}
+
+	for _, level := range []int{1, 4} {
+		t.Run(fmt.Sprintf("level-%d", level), func(t *testing.T) {
+			compressed, stats, err := CompressWithStats(input, Options{Level: level, Recovery: 5})
+			if err != nil {
+				t.Fatalf("CompressWithStats: %+v", err)
+			}
+
+			metaR, err := ParseMetadata(compressed)
+			if err != nil {
+				t.Fatalf("ParseMetadata: %+v", err)
+			}
+
+			if stats.CompressedSize != int64(len(compressed)) || stats.OriginalSize != int64(len(input)) || stats.EncodedSize != metaR.GetEncodedLen() {
+				t.Fatalf("sizes do not match the output: %+v", stats)
+			}
+
+			if stats.Overhead != stats.CompressedSize-stats.EncodedSize || stats.RecoverySize == 0 || stats.MetadataSize == 0 {
+				t.Fatalf("unexpected overhead: %+v", stats)
+			}
+
+			if len(stats.Blocks) != len(metaR.GetBlocks()) {
+				t.Fatalf("expected stats for %d blocks, got %d", len(metaR.GetBlocks()), len(stats.Blocks))
+			}
+
+			rleSize := 0
+			for idx, block := range stats.Blocks {
+				// no prefix code gets below the entropy of what it codes
+				if block.BitsPerSymbol < block.Entropy-1e-9 {
+					t.Fatalf("block %d takes %f bits/symbol, below its entropy %f", idx, block.BitsPerSymbol, block.Entropy)
+				}
+
+				if block.HuffmanOnly != (level == 1) || block.EncodedSize != metaR.GetBlocks()[idx].GetEncodedLen() {
+					t.Fatalf("block %d stats do not match its metadata: %+v", idx, block)
+				}
+
+				if !block.HuffmanOnly && (block.RleDictLen != len(metaR.GetBlocks()[idx].GetRleDict()) || block.BwtRuns != block.RleSize) {
+					t.Fatalf("block %d rle stats do not match its metadata: %+v", idx, block)
+				}
+
+				rleSize += block.RleSize
+			}
+
+			if stats.RleSize != rleSize {
+				t.Fatalf("expected rle size %d summed over the blocks, got %d", rleSize, stats.RleSize)
+			}
+
+			if level == 1 && (stats.BwtRuns != 0 || stats.Symbols != len(input)) {
+				t.Fatalf("expected huffman only stats to code the raw input, got %+v", stats)
+			}
+		})
+	}
+}
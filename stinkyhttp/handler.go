@@ -0,0 +1,170 @@
+package stinkyhttp
+
+import (
+	"bytes"
+	"net/http"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strconv"
+	"strings"
+)
+
+// value of the Accept-Encoding and Content-Encoding headers for stinky compressed bodies
+const ENCODING = "stinky"
+
+// smaller responses are sent as they are, the container header alone is larger than what they could save
+const MIN_SIZE = 256
+
+// responses are buffered up to MAX_BUFFER_SIZE, larger ones are streamed through unencoded
+const MAX_BUFFER_SIZE = 16 * 1024 * 1024
+
+// noTransform reports whether Cache-Control forbids changing the response, encoding it included
+func noTransform(header http.Header) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-transform") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// accepts reports whether an Accept-Encoding header allows stinky encoded responses, "stinky;q=0" refuses them
+func accepts(acceptEncoding string) bool {
+	for _, token := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(token), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), ENCODING) {
+			continue
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				quality, err := strconv.ParseFloat(value, 64)
+				return err == nil && quality > 0
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// the whole response is buffered since blocks are only encoded once their content is complete,
+// once the handler flushes or it grows over MAX_BUFFER_SIZE everything is passed through as it is
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	flushed bool
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.flushed && w.body.Len()+len(p) > MAX_BUFFER_SIZE {
+		w.passThrough()
+	}
+
+	if w.flushed {
+		return w.ResponseWriter.Write(p)
+	}
+
+	return w.body.Write(p)
+}
+
+// gives up on encoding and sends what was buffered so far
+func (w *bufferedResponseWriter) passThrough() {
+	if !w.flushed {
+		w.flushed = true
+		w.WriteHeader(http.StatusOK)
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body = bytes.Buffer{}
+	}
+}
+
+// Flush gives up on encoding, a handler that flushes wants what it wrote so far to reach the client now
+func (w *bufferedResponseWriter) Flush() {
+	w.passThrough()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// lets http.ResponseController reach the deadlines and hijacking of the wrapped writer
+func (w *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bufferedResponseWriter) shouldEncode(r *http.Request) bool {
+	header := w.Header()
+	switch {
+	case r.Method == http.MethodHead:
+		return false
+	case w.status < 200 || w.status >= 300 || w.status == http.StatusNoContent || w.status == http.StatusPartialContent:
+		// redirects, errors and responses without a full body are sent as they are
+		return false
+	case header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || noTransform(header):
+		return false
+	}
+
+	return w.body.Len() >= MIN_SIZE
+}
+
+func (w *bufferedResponseWriter) finish(r *http.Request, opts stinkycompressor.Options) {
+	if w.flushed {
+		return
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	body := w.body.Bytes()
+	header := w.Header()
+
+	if w.shouldEncode(r) {
+		compressed, err := stinkycompressor.Compress(body, opts)
+		// responses that would grow are not worth the decoding on the other end
+		if err == nil && len(compressed) < len(body) {
+			body = compressed
+			header.Set("Content-Encoding", ENCODING)
+			header.Set("Content-Length", strconv.Itoa(len(body)))
+
+			// the encoded body is a different representation of the resource
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
+
+// NewHandler wraps next so its responses are stinky compressed for clients that send "Accept-Encoding: stinky".
+// Responses are buffered in full before anything is sent, unless the handler flushes them or they grow over
+// MAX_BUFFER_SIZE, and responses with "Cache-Control: no-transform" are never encoded.
+// opts.Stats and opts.Progress are ignored since every request would share them
+func NewHandler(next http.Handler, opts stinkycompressor.Options) http.Handler {
+	opts.Stats, opts.Progress = nil, nil
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !accepts(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(buffered, r)
+		buffered.finish(r, opts)
+	})
+}
@@ -0,0 +1,160 @@
+package stinkyhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strings"
+	"testing"
+)
+
+var helperBody = strings.Repeat("The ancient oak tree stood as a silent sentinel at the edge of the meadow. ", 20)
+
+func helperHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"oak"`)
+		io.WriteString(w, helperBody)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "bananas")
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "identity")
+		io.WriteString(w, helperBody)
+	})
+	mux.HandleFunc("/no-transform", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, No-Transform")
+		io.WriteString(w, helperBody)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, helperBody, http.StatusNotFound)
+	})
+
+	return NewHandler(mux, stinkycompressor.Options{})
+}
+
+func TestAccepts(t *testing.T) {
+	cases := map[string]bool{
+		"":                       false,
+		"gzip, deflate":          false,
+		"stinky":                 true,
+		"gzip, Stinky;q=0.5":     true,
+		"stinky;q=0":             false,
+		"stinky ; q=0.0, gzip":   false,
+		"stinkyish, *":           false,
+		"br;q=1.0, stinky;q=1.0": true,
+	}
+
+	for header, expected := range cases {
+		if got := accepts(header); got != expected {
+			t.Fatalf("accepts(%q) = %t, expected %t", header, got, expected)
+		}
+	}
+}
+
+func TestHandlerEncodesWhenAccepted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/text", nil)
+	req.Header.Set("Accept-Encoding", "gzip, stinky")
+	rec := httptest.NewRecorder()
+	helperHandler().ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != ENCODING {
+		t.Fatalf("expected stinky Content-Encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	if rec.Header().Get("Vary") != "Accept-Encoding" || rec.Header().Get("ETag") != `W/"oak"` {
+		t.Fatalf("unexpected Vary %q or ETag %q", rec.Header().Get("Vary"), rec.Header().Get("ETag"))
+	}
+
+	decoded, err := stinkycompressor.DecodeCompressedFile(rec.Body.Bytes(), false)
+	if err != nil {
+		t.Fatalf("DecodeCompressedFile: %+v", err)
+	}
+
+	if string(decoded) != helperBody {
+		t.Fatalf("decoded body did not match")
+	}
+}
+
+func TestHandlerSendsOtherResponsesAsTheyAre(t *testing.T) {
+	cases := map[string]struct {
+		method         string
+		path           string
+		acceptEncoding string
+	}{
+		"not accepted":    {http.MethodGet, "/text", "gzip"},
+		"refused":         {http.MethodGet, "/text", "stinky;q=0"},
+		"small":           {http.MethodGet, "/small", "stinky"},
+		"already encoded": {http.MethodGet, "/encoded", "stinky"},
+		"error":           {http.MethodGet, "/missing", "stinky"},
+		"no transform":    {http.MethodGet, "/no-transform", "stinky"},
+		"head":            {http.MethodHead, "/text", "stinky"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rec := httptest.NewRecorder()
+			helperHandler().ServeHTTP(rec, req)
+
+			if rec.Header().Get("Content-Encoding") == ENCODING {
+				t.Fatalf("expected response to not be stinky encoded")
+			}
+		})
+	}
+}
+
+func TestHandlerPassesFlushedResponsesThrough(t *testing.T) {
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, helperBody)
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, "bananas")
+	}), stinkycompressor.Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "stinky")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != helperBody+"bananas" {
+		t.Fatalf("expected the flushed response unencoded, got flushed %t, encoding %q and %d bytes", rec.Flushed, rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+}
+
+func TestHandlerStreamsLargeResponsesThrough(t *testing.T) {
+	chunk := strings.Repeat("bobs burgers ", MAX_BUFFER_SIZE/13/4+1)
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 4 {
+			io.WriteString(w, chunk)
+		}
+	}), stinkycompressor.Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "stinky")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != strings.Repeat(chunk, 4) {
+		t.Fatalf("expected the large response unencoded, got encoding %q and %d bytes", rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+}
+
+func TestHandlerDoesNotShareStats(t *testing.T) {
+	stats := &stinkycompressor.Stats{}
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, helperBody)
+	}), stinkycompressor.Options{Stats: stats})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "stinky")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != ENCODING || len(stats.Blocks) != 0 {
+		t.Fatalf("expected an encoded response without filling in the shared stats, got %+v", stats)
+	}
+}
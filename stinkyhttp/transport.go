@@ -0,0 +1,75 @@
+package stinkyhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	sCError "stinky-compression/error"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"strconv"
+	"strings"
+)
+
+// Transport asks servers for stinky encoded responses and decodes them before they are returned,
+// like http.Transport does for gzip. Requests that set their own Accept-Encoding are left alone
+type Transport struct {
+	// used to send requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+	// limits for decoding response bodies, servers are untrusted input so limits left at zero get
+	// their stinkycompressor.DEFAULT_MAX value, a negative limit turns it off
+	DecodeOptions stinkycompressor.DecodeOptions
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", ENCODING)
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), ENCODING) || req.Method == http.MethodHead {
+		return resp, nil
+	}
+
+	compressed, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, &sCError.CompressorError{
+			Severity: sCError.COMPRESSOR_ERROR_SEVERITY_ERROR,
+			Code:     sCError.CODE_IO,
+			Message:  "failed to read stinky encoded response body",
+			Err:      err,
+		}
+	}
+
+	decoded := []byte{}
+	if len(compressed) > 0 {
+		decoded, err = stinkycompressor.DecodeContext(req.Context(), compressed, t.DecodeOptions.WithDefaultLimits())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(decoded))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(decoded)))
+	resp.ContentLength = int64(len(decoded))
+	resp.Uncompressed = true
+
+	return resp, nil
+}
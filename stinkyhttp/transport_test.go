@@ -0,0 +1,132 @@
+package stinkyhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	sCError "stinky-compression/error"
+	proto_data "stinky-compression/proto/proto-data"
+	stinkycompressor "stinky-compression/stinky-compressor"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTransportDecodesResponses(t *testing.T) {
+	server := httptest.NewServer(helperHandler())
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+
+	for _, path := range []string{"/text", "/small", "/missing"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Get %s: %+v", path, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ReadAll %s: %+v", path, err)
+		}
+
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s still has Content-Encoding %q", path, resp.Header.Get("Content-Encoding"))
+		}
+
+		if path == "/text" && (string(body) != helperBody || !resp.Uncompressed || resp.ContentLength != int64(len(helperBody))) {
+			t.Fatalf("unexpected decoded response for %s: %d bytes, uncompressed %t", path, len(body), resp.Uncompressed)
+		}
+	}
+}
+
+func TestTransportRequestsStinkyEncoding(t *testing.T) {
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %+v", err)
+	}
+	resp.Body.Close()
+
+	if acceptEncoding != ENCODING || req.Header.Get("Accept-Encoding") != "" {
+		t.Fatalf("expected server to get %q without touching the request, got %q", ENCODING, acceptEncoding)
+	}
+
+	// requests with their own Accept-Encoding get the raw response
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %+v", err)
+	}
+	resp.Body.Close()
+
+	if acceptEncoding != "gzip" {
+		t.Fatalf("expected Accept-Encoding to be left alone, got %q", acceptEncoding)
+	}
+}
+
+func TestTransportStaysWithinDecodeLimits(t *testing.T) {
+	server := httptest.NewServer(helperHandler())
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{
+		Base:          server.Client().Transport,
+		DecodeOptions: stinkycompressor.DecodeOptions{MaxOutputSize: int64(len(helperBody)) - 1},
+	}}
+
+	if _, err := client.Get(server.URL + "/text"); !errors.Is(err, sCError.ErrLimitExceeded) {
+		t.Fatalf("expected a limit error decoding a body over the limit, got %+v", err)
+	}
+}
+
+func TestTransportReportsCorruptBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", ENCODING)
+		io.WriteString(w, "this is not stinky")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatalf("expected error decoding a corrupt body")
+	}
+}
+
+func TestTransportDecodesWithDefaultLimits(t *testing.T) {
+	// blocks of single symbol runs claiming 2 GiB in a few KB
+	bomb := &proto_data.CompressedFileMetaData{Version: stinkycompressor.CONTAINER_VERSION}
+	for range 128 {
+		bomb.Blocks = append(bomb.Blocks, &proto_data.CompressedFileMetaData_Block{
+			OriginalSize: stinkycompressor.MAX_BLOCK_SIZE,
+			HuffmanOnly:  true,
+			Frequencies:  []*proto_data.CompressedFileMetaData_Frequency{{Char: []byte{'a'}, Frequency: stinkycompressor.MAX_BLOCK_SIZE}},
+		})
+	}
+
+	metaBts, err := proto.Marshal(bomb)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %+v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", ENCODING)
+		fmt.Fprintf(w, "%d#", len(metaBts))
+		w.Write(metaBts)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+	if _, err := client.Get(server.URL); !errors.Is(err, sCError.ErrLimitExceeded) {
+		t.Fatalf("expected a limit error decoding a body over the default limit, got %+v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package writer
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"stinky-compression/benchdata"
+	"testing"
+)
+
+func TestWriteBitsPacksBytesAndPadsTheLast(t *testing.T) {
+	out := &bytes.Buffer{}
+	writer := NewBitWriter(out)
+
+	for _, code := range []struct {
+		path uint64
+		size int
+	}{{0b101, 3}, {0b11110, 5}, {0b1, 1}} {
+		if err := writer.WriteBits(code.path, code.size); err != nil {
+			t.Fatalf("WriteBits: %+v", err)
+		}
+	}
+
+	padding, err := writer.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %+v", err)
+	}
+
+	if padding != 7 || !bytes.Equal(out.Bytes(), []byte{0b10111110, 0b10000000}) {
+		t.Fatalf("unexpected %08b with %d padding bits", out.Bytes(), padding)
+	}
+}
+
+// codes every byte with 1 to 9 bits, about the spread of lengths a huffman table has
+func BenchmarkWriteBits(b *testing.B) {
+	benchdata.Run(b, 64*benchdata.MB, func(b *testing.B, input []byte) {
+		for b.Loop() {
+			writer := NewBitWriter(io.Discard)
+			for _, bt := range input {
+				if err := writer.WriteBits(uint64(bt), bits.Len8(bt)+1); err != nil {
+					b.Fatalf("WriteBits: %+v", err)
+				}
+			}
+
+			if _, err := writer.Flush(); err != nil {
+				b.Fatalf("Flush: %+v", err)
+			}
+		}
+	})
+}